@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// dryRunProduction renders the deployment into a temporary directory, and prints how it differs from baseDir.
+// removed lists files that would be deleted from baseDir.
+func dryRunProduction(opts *ServerOptions, baseDir string, removed []string) error {
+	renderedDir, err := os.MkdirTemp("", "livekit-deploy")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(renderedDir)
+
+	if _, err = renderFiles(opts, renderedDir); err != nil {
+		return err
+	}
+	changed, err := printDirDiff(baseDir, renderedDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range removed {
+		fileChanged, err := printRemovedDiff(baseDir, name)
+		if err != nil {
+			return err
+		}
+		changed = changed || fileChanged
+	}
+
+	if !changed {
+		fmt.Println("No changes to", baseDir)
+	}
+	fmt.Println("Dry run, no files were written")
+	return nil
+}
+
+// printDirDiff prints a unified diff for every file rendered into renderedDir against the one in targetDir
+func printDirDiff(targetDir, renderedDir string) (bool, error) {
+	changed := false
+	err := filepath.WalkDir(renderedDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(renderedDir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		fileChanged, err := printFileDiff(path.Join(targetDir, name), name, data)
+		changed = changed || fileChanged
+		return err
+	})
+	return changed, err
+}
+
+// printRemovedDiff prints the removal of a file or of every file in a directory
+func printRemovedDiff(baseDir, name string) (bool, error) {
+	info, err := os.Stat(path.Join(baseDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return printFileDiff(path.Join(baseDir, name), name, nil)
+	}
+	changed := false
+	err = filepath.WalkDir(path.Join(baseDir, name), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fileName, err := filepath.Rel(baseDir, p)
+		if err != nil {
+			return err
+		}
+		fileChanged, err := printFileDiff(p, fileName, nil)
+		changed = changed || fileChanged
+		return err
+	})
+	return changed, err
+}
+
+// printFileDiff prints a unified diff between the file on disk and data, a nil data meaning the file is removed
+func printFileDiff(file, name string, data []byte) (bool, error) {
+	existing, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	fromFile := "a/" + name
+	if err != nil {
+		fromFile = "/dev/null"
+	}
+	toFile := "b/" + name
+	if data == nil {
+		toFile = "/dev/null"
+	}
+	if maskedFile(name) {
+		// never print certificate keys, or files embedding the secrets encoded
+		switch {
+		case bytes.Equal(existing, data):
+			return false, nil
+		case data == nil:
+			fmt.Println(name, "would be removed")
+		default:
+			fmt.Println(name, "would be updated")
+		}
+		return true, nil
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(redactSecrets(existing)),
+		B:        splitLines(redactSecrets(data)),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+	if err != nil {
+		return false, err
+	}
+	if diff == "" {
+		if bytes.Equal(existing, data) {
+			return false, nil
+		}
+		fmt.Println(name, "would be updated, with different secrets")
+		return true, nil
+	}
+	fmt.Print(diff)
+	return true, nil
+}
+
+// maskedFile is true for files whose changes are not printed at all
+func maskedFile(name string) bool {
+	name = filepath.ToSlash(name)
+	return strings.HasPrefix(name, certsDir+"/") || path.Base(name) == string(StartupScriptIgnition)
+}
+
+// secretValueRegexp matches API secrets, passwords and credentials in the configs, the .env file and the startup
+// scripts embedding them, i.e. the keys of livekit.yaml, api_secret, requirepass or the token of a DNS provider
+var secretValueRegexp = regexp.MustCompile(`(?im)^([ \t]*(?:"?[\w.-]*(?:secret|password|token|api_key|keys|secret_access_key)"?[ \t]*[:=]|(?-i:API[A-Za-z0-9]+):|requirepass)[ \t]*)\S.*$`)
+
+// redactSecrets replaces secret values, so that a dry run doesn't print them
+func redactSecrets(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	return secretValueRegexp.ReplaceAll(data, []byte("${1}<redacted>"))
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	redacted := redactSecrets([]byte(`keys:
+    APIkey: secret
+redis:
+    password: redis-password
+api_key: APIkey
+api_secret: secret
+LIVEKIT_KEYS={APIkey: secret}
+requirepass redis-password
+apiVersion: v1
+secrets_env: true
+`))
+	require.Equal(t, `keys:
+    APIkey: <redacted>
+redis:
+    password: <redacted>
+api_key: <redacted>
+api_secret: <redacted>
+LIVEKIT_KEYS=<redacted>
+requirepass <redacted>
+apiVersion: v1
+secrets_env: true
+`, string(redacted))
+
+	require.True(t, maskedFile("certs/livekit.myhost.com.key"))
+	require.True(t, maskedFile("ignition.json"))
+	require.False(t, maskedFile("livekit.yaml"))
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"fmt"
-	"os"
 
+	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v3"
 
 	"github.com/livekit/livekit-server/pkg/config"
@@ -12,7 +12,11 @@ import (
 	"github.com/livekit/protocol/utils"
 )
 
-func generateLocal() error {
+func generateLocal(c *cli.Context) error {
+	testToken, err := resolveTestTokenOptions(c, false)
+	if err != nil {
+		return err
+	}
 	apiKey := utils.NewGuid(utils.APIKeyPrefix)
 	apiSecret := utils.RandomSecret()
 	conf := config.Config{
@@ -35,17 +39,20 @@ func generateLocal() error {
 		},
 	}
 
-	out, err := os.Create(outputPath("livekit.yaml"))
+	data, err := yaml.Marshal(&conf)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-
-	data, err := yaml.Marshal(&conf)
-	if err != nil {
+	if c.Bool(flagDryRun) {
+		changed, err := printFileDiff(outputPath("livekit.yaml"), "livekit.yaml", data)
+		if err == nil && !changed {
+			fmt.Println("No changes to livekit.yaml")
+		}
+		fmt.Println("Dry run, no files were written")
 		return err
 	}
-	err = os.WriteFile(outputPath("livekit.yaml"), data, filePerms)
+
+	err = writeSecretFile(outputPath("livekit.yaml"), data)
 	if err != nil {
 		return err
 	}
@@ -79,5 +86,5 @@ func generateLocal() error {
 	}
 
 	fmt.Println("Server URL: ", "ws://localhost:7880")
-	return printKeysAndToken(apiKey, apiSecret)
+	return printKeysAndToken(apiKey, apiSecret, "ws://localhost:7880", testToken)
 }
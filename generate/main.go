@@ -13,10 +13,17 @@ import (
 )
 
 const (
-	filePerms    = 0644
-	dockerOutput = "/output"
+	filePerms = 0644
+	// secretFilePerms is for files holding API secrets, passwords or private keys
+	secretFilePerms = 0600
+	// secretDirPerms is for the directories of deployments
+	secretDirPerms = 0700
+	dockerOutput   = "/output"
 )
 
+// version is set to the release tag by the release workflow
+var version = "dev"
+
 func init() {
 	rand.Seed(time.Now().Unix())
 }
@@ -25,13 +32,43 @@ func main() {
 	app := &cli.App{
 		Name:    "generate",
 		Usage:   "Generates Configurations for LiveKit",
-		Version: "1.0.0",
+		Version: version,
 		Action:  startGenerator,
-		Flags: []cli.Flag{
+		Flags: append(append([]cli.Flag{
 			&cli.BoolFlag{
 				Name:  "local",
 				Usage: "generates local config",
 			},
+		}, productionFlags...), testTokenFlags...),
+		Commands: []*cli.Command{
+			{
+				Name:      "update",
+				Usage:     "Regenerates an existing production deployment, keeping its API keys",
+				ArgsUsage: "<dir>",
+				Action:    updateProduction,
+				Flags:     append(append([]cli.Flag{}, productionFlags...), testTokenFlags...),
+			},
+			{
+				Name:      "rotate-keys",
+				Usage:     "Adds a new API key to an existing deployment, or retires one replaced by a previous rotation",
+				ArgsUsage: "<dir>",
+				Action:    rotateKeys,
+				Flags:     rotateKeysFlags,
+			},
+			{
+				Name:      "update-ip",
+				Usage:     "Points Caddy's TURN/TLS upstream to the local IP, run on the server before Caddy starts",
+				ArgsUsage: "[caddy.yaml]",
+				Action:    updateIP,
+				Flags:     updateIPFlags,
+			},
+			{
+				Name:      "token",
+				Usage:     "Creates an access token signed with the API key of a deployment",
+				ArgsUsage: "[livekit.yaml]",
+				Action:    createToken,
+				Flags:     tokenFlags,
+			},
 		},
 	}
 
@@ -42,33 +79,66 @@ func main() {
 
 func startGenerator(c *cli.Context) error {
 	if c.Bool("local") {
-		return generateLocal()
+		return generateLocal(c)
 	}
-	return generateProduction()
+	return generateProduction(c)
+}
+
+// printKeysAndToken prints the keys and a short-lived token joining the test room
+func printKeysAndToken(apiKey, apiSecret, serverURL string, testToken *testTokenOptions) error {
+	fmt.Println("API Key: " + apiKey)
+	fmt.Println("API Secret: " + apiSecret)
+	fmt.Println()
+	return printTestToken(apiKey, apiSecret, serverURL, testToken)
 }
 
-func printKeysAndToken(apiKey, apiSecret string) error {
+// printTestToken prints a short-lived token joining the test room, or a meet.livekit.io URL with it
+func printTestToken(apiKey, apiSecret, serverURL string, testToken *testTokenOptions) error {
 	token := auth.NewAccessToken(apiKey, apiSecret)
 	token.SetIdentity("test-user")
 	token.SetName("Test User")
 	token.AddGrant(&auth.VideoGrant{
-		Room:     "my-first-room",
+		Room:     testToken.Room,
 		RoomJoin: true,
 	})
-	token.SetValidFor(10000 * time.Hour)
+	token.SetValidFor(testToken.ValidFor)
 	jwt, err := token.ToJWT()
 	if err != nil {
 		return err
 	}
-	fmt.Println("API Key: " + apiKey)
-	fmt.Println("API Secret: " + apiSecret)
-	fmt.Println()
-	fmt.Println("Here's a test token generated with your keys: " + jwt)
+	if testToken.URL {
+		fmt.Printf("Join %s for the next %s: %s\n", testToken.Room, testToken.ValidFor, testMeetURL(serverURL, jwt))
+	} else {
+		fmt.Printf("Here's a test token joining %s, valid for %s: %s\n", testToken.Room, testToken.ValidFor, jwt)
+	}
 	fmt.Println()
 	fmt.Println("An access token identifies the participant as well as the room it's connecting to")
+	fmt.Println("Run \"generate token\" to create tokens with other identities, rooms and permissions")
 	return nil
 }
 
+// writeSecretFile writes a file only its owner can read. os.WriteFile leaves the permissions
+// of existing files alone, so those written by earlier versions are restricted as well.
+func writeSecretFile(name string, data []byte) error {
+	if err := os.WriteFile(name, data, secretFilePerms); err != nil {
+		return err
+	}
+	return os.Chmod(name, secretFilePerms)
+}
+
+// createSecretFile is os.Create for files only their owner can read
+func createSecretFile(name string) (*os.File, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, secretFilePerms)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Chmod(secretFilePerms); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
 // map differences between docker environment
 func outputPath(file string) string {
 	if !isDocker() {
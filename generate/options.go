@@ -1,8 +1,29 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+
 	"github.com/livekit/deploy/generate/templates"
 	"github.com/livekit/protocol/redis"
+	"github.com/livekit/protocol/utils"
+)
+
+const (
+	// deployFile holds the answers used to generate a deployment
+	deployFile = "deploy.yaml"
+	// certsDir holds certificates supplied with the custom SSL issuer
+	certsDir = "certs"
+	// installPrefix is where startup scripts install the deployment on the server
+	installPrefix = "/opt/livekit"
 )
 
 type StartupScriptKind string
@@ -12,27 +33,72 @@ const (
 	StartupScriptCloudInitAmazon StartupScriptKind = "cloud_init.amazon.yaml"
 	StartupScriptCloudInitUbuntu StartupScriptKind = "cloud_init.ubuntu.yaml"
 	StartupScriptShellScript     StartupScriptKind = "init_script.sh"
+	StartupScriptCloudInitAL2023 StartupScriptKind = "cloud_init.amazon2023.yaml"
+	StartupScriptCloudInitDebian StartupScriptKind = "cloud_init.debian.yaml"
+	StartupScriptCloudInitRHEL   StartupScriptKind = "cloud_init.rhel.yaml"
+	// StartupScriptIgnition is rendered as JSON rather than from a template
+	StartupScriptIgnition StartupScriptKind = "ignition.json"
 )
 
+// startupScriptKinds lists the startup scripts in the order they are offered
+var startupScriptKinds = []StartupScriptKind{
+	StartupScriptShellScript,
+	StartupScriptCloudInitAmazon,
+	StartupScriptCloudInitAL2023,
+	StartupScriptCloudInitUbuntu,
+	StartupScriptCloudInitDebian,
+	StartupScriptCloudInitRHEL,
+	StartupScriptIgnition,
+	StartupScriptNone,
+}
+
 func (k StartupScriptKind) Description() string {
 	switch k {
 	case StartupScriptCloudInitAmazon:
 		return "Cloud Init for Amazon Linux"
 	case StartupScriptCloudInitUbuntu:
 		return "Cloud Init for Ubuntu"
+	case StartupScriptCloudInitAL2023:
+		return "Cloud Init for Amazon Linux 2023"
+	case StartupScriptCloudInitDebian:
+		return "Cloud Init for Debian 12"
+	case StartupScriptCloudInitRHEL:
+		return "Cloud Init for Rocky Linux and AlmaLinux 9"
 	case StartupScriptShellScript:
 		return "Startup Shell Script"
+	case StartupScriptIgnition:
+		return "Ignition for Fedora CoreOS and Flatcar"
 	default:
 		return "Skip"
 	}
 }
 
+// Firewalls lists the firewalls the startup script can install
+func (k StartupScriptKind) Firewalls() []FirewallKind {
+	switch k {
+	case StartupScriptIgnition:
+		// Fedora CoreOS and Flatcar only ship nftables
+		return []FirewallKind{FirewallNftables}
+	case StartupScriptCloudInitAmazon, StartupScriptCloudInitAL2023, StartupScriptCloudInitRHEL:
+		// ufw is only packaged for Debian and Ubuntu
+		return []FirewallKind{FirewallFirewalld, FirewallNftables}
+	default:
+		return firewallKinds
+	}
+}
+
 func (k StartupScriptKind) Template() string {
 	switch k {
 	case StartupScriptCloudInitAmazon:
 		return templates.CloudInitAmazon2Template
 	case StartupScriptCloudInitUbuntu:
 		return templates.CloudInitUbuntuTemplate
+	case StartupScriptCloudInitAL2023:
+		return templates.CloudInitAmazon2023Template
+	case StartupScriptCloudInitDebian:
+		return templates.CloudInitDebianTemplate
+	case StartupScriptCloudInitRHEL:
+		return templates.CloudInitRHELTemplate
 	case StartupScriptShellScript:
 		return templates.StartupScriptTemplate
 	default:
@@ -40,49 +106,570 @@ func (k StartupScriptKind) Template() string {
 	}
 }
 
+// Name is the short identifier used to select the kind on the command line
+func (k StartupScriptKind) Name() string {
+	switch k {
+	case StartupScriptCloudInitAmazon:
+		return "amazon"
+	case StartupScriptCloudInitUbuntu:
+		return "ubuntu"
+	case StartupScriptCloudInitAL2023:
+		return "amazon2023"
+	case StartupScriptCloudInitDebian:
+		return "debian"
+	case StartupScriptCloudInitRHEL:
+		return "rhel"
+	case StartupScriptShellScript:
+		return "shell"
+	case StartupScriptIgnition:
+		return "ignition"
+	default:
+		return "none"
+	}
+}
+
+func CloudInitFromName(str string) (StartupScriptKind, error) {
+	for _, k := range startupScriptKinds {
+		if k.Name() == str {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown startup script %q", str)
+}
+
+// UsesComposePlugin is true for startup scripts that install compose as a Docker CLI plugin,
+// rather than the standalone docker-compose binary
+func (k StartupScriptKind) UsesComposePlugin() bool {
+	switch k {
+	case StartupScriptCloudInitAL2023, StartupScriptCloudInitDebian, StartupScriptCloudInitRHEL:
+		return true
+	default:
+		return false
+	}
+}
+
+// MarshalYAML writes the short name, which is easier to edit by hand than the file name
+func (k StartupScriptKind) MarshalYAML() (interface{}, error) {
+	return k.Name(), nil
+}
+
+func (k *StartupScriptKind) UnmarshalYAML(value *yaml.Node) error {
+	kind, err := CloudInitFromName(value.Value)
+	if err != nil {
+		return err
+	}
+	*k = kind
+	return nil
+}
+
 func CloudInitFromDescription(str string) StartupScriptKind {
 	switch str {
 	case StartupScriptCloudInitAmazon.Description():
 		return StartupScriptCloudInitAmazon
 	case StartupScriptCloudInitUbuntu.Description():
 		return StartupScriptCloudInitUbuntu
+	case StartupScriptCloudInitAL2023.Description():
+		return StartupScriptCloudInitAL2023
+	case StartupScriptCloudInitDebian.Description():
+		return StartupScriptCloudInitDebian
+	case StartupScriptCloudInitRHEL.Description():
+		return StartupScriptCloudInitRHEL
 	case StartupScriptShellScript.Description():
 		return StartupScriptShellScript
+	case StartupScriptIgnition.Description():
+		return StartupScriptIgnition
 	default:
 		return StartupScriptNone
 	}
 }
 
+// DeploymentTarget is the runtime the generated deployment is meant for
+type DeploymentTarget string
+
+const (
+	TargetCompose    DeploymentTarget = "compose"
+	TargetKubernetes DeploymentTarget = "kubernetes"
+	TargetHelm       DeploymentTarget = "helm"
+	// TargetSystemd runs the services as native binaries, for hosts without Docker
+	TargetSystemd DeploymentTarget = "systemd"
+	// TargetPodman runs the containers with Podman, as Quadlet units
+	TargetPodman DeploymentTarget = "podman"
+)
+
+// IsKubernetes is true for targets that run in a cluster, where cert-manager takes the place of Caddy
+func (t DeploymentTarget) IsKubernetes() bool {
+	return t == TargetKubernetes || t == TargetHelm
+}
+
+// StartupScripts lists the startup scripts that can install the target
+func (t DeploymentTarget) StartupScripts() []StartupScriptKind {
+	switch t {
+	case TargetSystemd:
+		return []StartupScriptKind{StartupScriptShellScript, StartupScriptNone}
+	case TargetPodman:
+		return []StartupScriptKind{StartupScriptShellScript, StartupScriptIgnition, StartupScriptNone}
+	case TargetKubernetes, TargetHelm:
+		return []StartupScriptKind{StartupScriptNone}
+	default:
+		return startupScriptKinds
+	}
+}
+
+type SSLIssuer string
+
+const (
+	SSLIssuerLetsEncrypt SSLIssuer = "letsencrypt"
+	SSLIssuerZeroSSL     SSLIssuer = "zerossl"
+	// SSLIssuerCustom uses certificates supplied by the user, i.e. from an internal CA
+	SSLIssuerCustom SSLIssuer = "custom"
+	// SSLIssuerExternal leaves TLS to a load balancer in front of the server, which takes the place of Caddy
+	SSLIssuerExternal SSLIssuer = "external"
+)
+
+// FirewallKind is the host firewall configured by the startup script
+type FirewallKind string
+
+const (
+	FirewallNone      FirewallKind = ""
+	FirewallUFW       FirewallKind = "ufw"
+	FirewallFirewalld FirewallKind = "firewalld"
+	FirewallNftables  FirewallKind = "nftables"
+)
+
+var firewallKinds = []FirewallKind{FirewallUFW, FirewallFirewalld, FirewallNftables}
+
+// Script is the name of the firewall's script in firewallDir
+func (f FirewallKind) Script() string {
+	return string(f) + ".sh"
+}
+
+func (f FirewallKind) Template() string {
+	switch f {
+	case FirewallUFW:
+		return templates.FirewallUFWTemplate
+	case FirewallFirewalld:
+		return templates.FirewallFirewalldTemplate
+	case FirewallNftables:
+		return templates.FirewallNftablesTemplate
+	default:
+		return ""
+	}
+}
+
+// LabeledKey names one of the API keys in livekit.yaml, the key is generated when empty
+type LabeledKey struct {
+	Label string `yaml:"label"`
+	Key   string `yaml:"key,omitempty"`
+}
+
+var keyLabelRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// setKeyLabels replaces the labeled keys, labels that are kept keep their key
+func (o *ServerOptions) setKeyLabels(labels []string) {
+	existing := make(map[string]string)
+	for _, k := range o.LabeledKeys {
+		existing[k.Label] = k.Key
+	}
+	o.LabeledKeys = nil
+	for _, label := range labels {
+		o.LabeledKeys = append(o.LabeledKeys, LabeledKey{Label: label, Key: existing[label]})
+	}
+}
+
+// KeyLabel is the label of an API key, empty when it has none
+func (o *ServerOptions) KeyLabel(key string) string {
+	for _, k := range o.LabeledKeys {
+		if k.Key == key {
+			return k.Label
+		}
+	}
+	return ""
+}
+
+// CertificateFiles is a certificate and its key for one of the domains
+type CertificateFiles struct {
+	Domain   string `yaml:"domain"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// RedisOptions is the connection to an external Redis. The bundled Redis only uses the password.
+type RedisOptions struct {
+	Address            string   `yaml:"address,omitempty"`
+	Username           string   `yaml:"username,omitempty"`
+	Password           string   `yaml:"password,omitempty"`
+	DB                 int      `yaml:"db,omitempty"`
+	UseTLS             bool     `yaml:"use_tls,omitempty"`
+	SentinelMasterName string   `yaml:"sentinel_master_name,omitempty"`
+	SentinelAddresses  []string `yaml:"sentinel_addresses,omitempty"`
+	SentinelPassword   string   `yaml:"sentinel_password,omitempty"`
+}
+
+// IsSentinel is true when the master is discovered through Redis Sentinel
+func (r *RedisOptions) IsSentinel() bool {
+	return r.SentinelMasterName != ""
+}
+
 // ServerOptions contains options for the SFU
 type ServerOptions struct {
-	IncludeEgress  bool
-	IncludeIngress bool
-	Domain         string
-	TURNDomain     string
-	WHIPDomain     string // optional, only if WHIP is desired
-	ServerVersion  string
-	ZeroSSLAPIKey  string
-	LocalRedis     bool
-	CloudInit      StartupScriptKind
+	IncludeEgress  bool               `yaml:"include_egress"`
+	IncludeIngress bool               `yaml:"include_ingress"`
+	Domain         string             `yaml:"domain"`
+	TURNDomain     string             `yaml:"turn_domain"`
+	WHIPDomain     string             `yaml:"whip_domain,omitempty"` // optional, only if WHIP is desired
+	ServerVersion  string             `yaml:"server_version"`
+	SSLIssuer      SSLIssuer          `yaml:"ssl_issuer"`
+	ZeroSSLAPIKey  string             `yaml:"zerossl_api_key,omitempty"`
+	Certificates   []CertificateFiles `yaml:"certificates,omitempty"` // only with the custom SSL issuer
+	DNSProvider    string             `yaml:"dns_provider,omitempty"` // solve ACME challenges with DNS-01 instead of HTTP
+	DNSCredentials map[string]string  `yaml:"dns_credentials,omitempty"`
+	LocalRedis     bool               `yaml:"local_redis"`
+	Redis          RedisOptions       `yaml:"redis,omitempty"`
+	CloudInit      StartupScriptKind  `yaml:"startup_script"`
+	Target         DeploymentTarget   `yaml:"target"`
+	Nodes          []string           `yaml:"nodes,omitempty"` // hostnames or IPs of the nodes in a cluster
+	Terraform      bool               `yaml:"terraform,omitempty"`
+	Firewall       FirewallKind       `yaml:"firewall,omitempty"`    // configured by the startup script
+	DualStack      bool               `yaml:"dual_stack,omitempty"`  // serve clients over IPv6 as well as IPv4
+	SecretsEnv     bool               `yaml:"secrets_env,omitempty"` // API keys in .env instead of the configs
+
+	// APIKey is the key Egress, Ingress and the test token use when livekit.yaml has several, i.e. during a rotation
+	APIKey string `yaml:"api_key,omitempty"`
+	// LabeledKeys are separate keys for the products sharing the server, the first one is used as APIKey
+	LabeledKeys []LabeledKey `yaml:"labeled_keys,omitempty"`
+
+	// Keys are reused instead of generating a new pair when set
+	Keys  map[string]string `yaml:"-"`
+	Files ConfigFiles       `yaml:"-"`
+
+	// livekit.yaml of the deployment being updated, settings the generator doesn't manage are kept
+	existingLiveKit *yaml.Node
+}
+
+// loadServerOptions reads answers previously written by saveServerOptions
+// values missing from the file keep what's already in opts
+func loadServerOptions(file string, opts *ServerOptions) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(data, opts); err != nil {
+		return fmt.Errorf("could not parse %s: %w", file, err)
+	}
+	return nil
+}
 
-	Files ConfigFiles
+// saveServerOptions records the resolved answers, so that the deployment can be generated again
+func saveServerOptions(opts *ServerOptions, baseDir string) error {
+	data, err := yaml.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	opts.Files.Deploy = path.Join(baseDir, deployFile)
+	return writeSecretFile(opts.Files.Deploy, data)
 }
 
 func (o *ServerOptions) RedisConfig() *redis.RedisConfig {
-	c := &redis.RedisConfig{}
-	if o.LocalRedis {
+	c := &redis.RedisConfig{
+		Password: o.Redis.Password,
+	}
+	if o.LocalRedis && o.Target == TargetKubernetes {
+		c.Address = fmt.Sprintf("redis.%s.svc.cluster.local:6379", kubernetesNamespace)
+	} else if o.LocalRedis {
 		c.Address = "localhost:6379"
 	} else {
-		c.Address = "<redis-host>:6379"
+		c.Address = o.Redis.Address
+		c.Username = o.Redis.Username
+		c.DB = o.Redis.DB
+		c.UseTLS = o.Redis.UseTLS
+		c.MasterName = o.Redis.SentinelMasterName
+		c.SentinelAddresses = o.Redis.SentinelAddresses
+		c.SentinelPassword = o.Redis.SentinelPassword
 	}
 	return c
 }
 
+// Domains lists the domains that require TLS certificates
+func (o *ServerOptions) Domains() []string {
+	domains := []string{o.Domain}
+	if o.IsCluster() {
+		for _, node := range o.Nodes {
+			domains = append(domains, o.NodeTURNDomain(node))
+		}
+	} else {
+		domains = append(domains, o.TURNDomain)
+	}
+	if o.WHIPDomain != "" {
+		domains = append(domains, o.WHIPDomain)
+	}
+	return domains
+}
+
+// Certificate returns the certificate files supplied for domain, nil if there are none
+func (o *ServerOptions) Certificate(domain string) *CertificateFiles {
+	for i := range o.Certificates {
+		if o.Certificates[i].Domain == domain {
+			return &o.Certificates[i]
+		}
+	}
+	return nil
+}
+
+// UsesCaddy is true when Caddy terminates TLS on the server
+func (o *ServerOptions) UsesCaddy() bool {
+	return !o.Target.IsKubernetes() && o.SSLIssuer != SSLIssuerExternal
+}
+
+// CaddyImage is the Caddy build to deploy, DNS challenges require a variant built with the provider's module
+func (o *ServerOptions) CaddyImage() string {
+	if o.DNSProvider != "" {
+		return "livekit/caddyl4:latest-" + o.DNSProvider
+	}
+	return "livekit/caddyl4"
+}
+
+// CaddyModules are the modules Caddy is built with, matching the livekit/caddyl4 image
+func (o *ServerOptions) CaddyModules() []string {
+	modules := []string{"github.com/mholt/caddy-l4", "github.com/abiosoft/caddy-yaml"}
+	if o.DNSProvider != "" {
+		modules = append(modules, "github.com/caddy-dns/"+o.DNSProvider)
+	}
+	return modules
+}
+
+// CaddyDataDir is where Caddy stores issued certificates
+func (o *ServerOptions) CaddyDataDir() string {
+	if o.Target == TargetSystemd {
+		return path.Join(installPrefix, "caddy_data")
+	}
+	return "/data"
+}
+
+// CaddyCertsDir is where Caddy reads certificates supplied with the custom SSL issuer
+func (o *ServerOptions) CaddyCertsDir() string {
+	if o.Target == TargetSystemd {
+		return path.Join(installPrefix, certsDir)
+	}
+	return "/etc/caddy/certs"
+}
+
+// setDefaults fills in optional values that were not supplied
+func (o *ServerOptions) setDefaults() {
+	if o.SSLIssuer == "" {
+		o.SSLIssuer = SSLIssuerLetsEncrypt
+	}
+	if o.ServerVersion == "" {
+		o.ServerVersion = "latest"
+	}
+	if o.CloudInit == "" {
+		o.CloudInit = StartupScriptNone
+	}
+	if o.Target == "" {
+		o.Target = TargetCompose
+	}
+	if o.LocalRedis && o.Redis.Password == "" {
+		// the bundled Redis always requires a password, kept in the answers so that it's stable across runs
+		o.Redis.Password = utils.RandomSecret()
+	}
+}
+
+// Validate ensures options are complete and consistent, regardless of whether they came from prompts or flags
+func (o *ServerOptions) Validate() error {
+	if o.Domain == "" {
+		return errors.New("primary domain is required")
+	}
+	if err := validateDomain(o.Domain); err != nil {
+		return fmt.Errorf("primary domain %s: %w", o.Domain, err)
+	}
+	if o.TURNDomain == "" {
+		return errors.New("TURN domain is required")
+	}
+	if err := validateDomain(o.TURNDomain); err != nil {
+		return fmt.Errorf("TURN domain %s: %w", o.TURNDomain, err)
+	}
+	if o.TURNDomain == o.Domain {
+		return errors.New("TURN domain cannot be same as primary domain name")
+	}
+	if o.WHIPDomain != "" {
+		if !o.IncludeIngress {
+			return errors.New("WHIP domain requires Ingress")
+		}
+		if err := validateDomain(o.WHIPDomain); err != nil {
+			return fmt.Errorf("WHIP domain %s: %w", o.WHIPDomain, err)
+		}
+		if o.WHIPDomain == o.Domain {
+			return errors.New("WHIP domain cannot be same as primary domain name")
+		}
+	}
+	switch o.SSLIssuer {
+	case SSLIssuerLetsEncrypt:
+	case SSLIssuerZeroSSL:
+		if o.ZeroSSLAPIKey == "" {
+			return errors.New("ZeroSSL API key is required when using ZeroSSL")
+		}
+	case SSLIssuerExternal:
+		if o.Target.IsKubernetes() {
+			return fmt.Errorf("the %s target issues certificates with cert-manager and Let's Encrypt", o.Target)
+		}
+	case SSLIssuerCustom:
+		for _, domain := range o.Domains() {
+			cert := o.Certificate(domain)
+			if cert == nil || cert.CertFile == "" || cert.KeyFile == "" {
+				return fmt.Errorf("certificate and key files are required for %s", domain)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown SSL issuer %q", o.SSLIssuer)
+	}
+	if o.SSLIssuer != SSLIssuerCustom && len(o.Certificates) != 0 {
+		return errors.New("certificate files require the custom SSL issuer")
+	}
+	if o.DNSProvider != "" {
+		if o.SSLIssuer != SSLIssuerLetsEncrypt || o.Target == TargetHelm {
+			return errors.New("DNS challenges are only available with Let's Encrypt, and not with the helm target")
+		}
+		provider, err := getDNSProvider(o.DNSProvider)
+		if err != nil {
+			return err
+		}
+		for field := range o.DNSCredentials {
+			if !slices.Contains(provider.Credentials, field) {
+				return fmt.Errorf("unknown %s credential %q", provider.Name, field)
+			}
+		}
+	}
+	if err := o.Redis.validate(o.LocalRedis); err != nil {
+		return err
+	}
+	if o.ServerVersion != "latest" {
+		if err := validateVersion(o.ServerVersion); err != nil {
+			return fmt.Errorf("server version %s: %w", o.ServerVersion, err)
+		}
+	}
+	if !slices.Contains(startupScriptKinds, o.CloudInit) {
+		return fmt.Errorf("unknown startup script %q", o.CloudInit)
+	}
+	switch o.Target {
+	case TargetCompose:
+	case TargetSystemd, TargetPodman:
+		if !slices.Contains(o.Target.StartupScripts(), o.CloudInit) {
+			return fmt.Errorf("startup script %s is not available for the %s target", o.CloudInit.Name(), o.Target)
+		}
+		if o.Target == TargetSystemd && o.ServerVersion != "latest" && strings.Count(o.ServerVersion, ".") != 2 {
+			return fmt.Errorf("the systemd target downloads a release, server version %s requires a patch version (i.e. v1.4.3)", o.ServerVersion)
+		}
+	case TargetKubernetes, TargetHelm:
+		if o.CloudInit != StartupScriptNone {
+			return fmt.Errorf("startup scripts are not available for the %s target", o.Target)
+		}
+		if o.SSLIssuer != SSLIssuerLetsEncrypt {
+			return fmt.Errorf("the %s target issues certificates with cert-manager and Let's Encrypt", o.Target)
+		}
+		if o.Target == TargetHelm && o.LocalRedis {
+			return errors.New("the livekit-server chart does not bundle Redis, an external Redis is required")
+		}
+	default:
+		return fmt.Errorf("unknown target %q", o.Target)
+	}
+	if o.Firewall != FirewallNone {
+		if !slices.Contains(firewallKinds, o.Firewall) {
+			return fmt.Errorf("unknown firewall %q", o.Firewall)
+		}
+		if o.CloudInit == StartupScriptNone {
+			return errors.New("the firewall is configured by the startup script, which is required")
+		}
+		if !slices.Contains(o.CloudInit.Firewalls(), o.Firewall) {
+			return fmt.Errorf("firewall %s is not available with the %s startup script", o.Firewall, o.CloudInit.Description())
+		}
+	}
+	if o.Terraform {
+		if o.CloudInit == StartupScriptNone {
+			return errors.New("Terraform launches the server with the startup script, which is required")
+		}
+		if o.IsCluster() {
+			return errors.New("Terraform is only available for single server deployments")
+		}
+		if o.SSLIssuer == SSLIssuerExternal {
+			return errors.New("Terraform points the domains to the server, which conflicts with an external load balancer")
+		}
+	}
+	if o.SecretsEnv && o.Target != TargetCompose {
+		return fmt.Errorf("the %s target doesn't support a .env file, the API keys stay in the configs", o.Target)
+	}
+	labels := make(map[string]bool)
+	for _, k := range o.LabeledKeys {
+		if !keyLabelRegexp.MatchString(k.Label) {
+			return fmt.Errorf("invalid key label %q, use letters, digits, '_', '.' and '-'", k.Label)
+		}
+		if labels[k.Label] {
+			return fmt.Errorf("duplicate key label %s", k.Label)
+		}
+		labels[k.Label] = true
+	}
+	if o.IsCluster() {
+		if o.Target.IsKubernetes() {
+			return fmt.Errorf("the %s target scales with replicas instead of a cluster of nodes", o.Target)
+		}
+		if o.LocalRedis {
+			return errors.New("nodes of a cluster must share an external Redis")
+		}
+		if o.SSLIssuer == SSLIssuerExternal {
+			return errors.New("nodes of a cluster terminate TURN/TLS with Caddy, an external load balancer can't terminate TLS for them")
+		}
+		if o.SSLIssuer != SSLIssuerCustom && o.DNSProvider == "" {
+			// the load balancer forwards HTTP challenges for the shared domain to any of the nodes
+			return fmt.Errorf("nodes of a cluster share %s, its certificate requires DNS challenges (--dns-provider) or your own certificates", o.Domain)
+		}
+		if err := validateNodes(o.Nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RedisOptions) validate(local bool) error {
+	if local {
+		if r.Address != "" || r.Username != "" || r.DB != 0 || r.UseTLS || r.IsSentinel() || len(r.SentinelAddresses) != 0 {
+			return errors.New("Redis connection settings require an external Redis, the bundled Redis only takes a password")
+		}
+		return nil
+	}
+	if r.Address == "" && !r.IsSentinel() && len(r.SentinelAddresses) == 0 {
+		return errors.New("external Redis requires an address, or a Sentinel master name and addresses")
+	}
+	if r.Address != "" {
+		if _, _, err := net.SplitHostPort(r.Address); err != nil {
+			return fmt.Errorf("Redis address %s: %w", r.Address, err)
+		}
+	}
+	if r.IsSentinel() != (len(r.SentinelAddresses) != 0) {
+		return errors.New("Redis Sentinel requires both a master name and the sentinel addresses")
+	}
+	if r.IsSentinel() && r.Address != "" {
+		return errors.New("Redis address cannot be used with Sentinel, the master is discovered through the sentinels")
+	}
+	for _, addr := range r.SentinelAddresses {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("Redis Sentinel address %s: %w", addr, err)
+		}
+	}
+	if r.DB < 0 {
+		return errors.New("Redis DB index cannot be negative")
+	}
+	return nil
+}
+
 type ConfigFiles struct {
-	LiveKit   string
-	Egress    string
-	Ingress   string
-	Caddy     string
-	Docker    string
-	RedisConf string
+	Deploy       string
+	LiveKit      string
+	Egress       string
+	Ingress      string
+	Caddy        string
+	Docker       string
+	RedisConf    string
+	Manifest     string
+	Certificates []string
+	Units        []string
+	Firewall     string
+	Env          string
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerOptionsRoundTrip(t *testing.T) {
+	opts := &ServerOptions{
+		IncludeIngress: true,
+		Domain:         "livekit.myhost.com",
+		TURNDomain:     "livekit-turn.myhost.com",
+		WHIPDomain:     "livekit-whip.myhost.com",
+		ServerVersion:  "v1.4.3",
+		SSLIssuer:      SSLIssuerZeroSSL,
+		ZeroSSLAPIKey:  "zerossl-key",
+		LocalRedis:     true,
+		Redis:          RedisOptions{Password: "redis-password"},
+		CloudInit:      StartupScriptCloudInitUbuntu,
+		Target:         TargetCompose,
+		APIKey:         "APIservice",
+		LabeledKeys:    []LabeledKey{{Label: "service", Key: "APIservice"}, {Label: "product", Key: "APIproduct"}},
+	}
+	require.NoError(t, opts.Validate())
+
+	dir := t.TempDir()
+	require.NoError(t, saveServerOptions(opts, dir))
+
+	loaded := &ServerOptions{}
+	require.NoError(t, loadServerOptions(opts.Files.Deploy, loaded))
+	loaded.Files = opts.Files
+	require.Equal(t, opts, loaded)
+}
+
+func TestFirewallStartupScripts(t *testing.T) {
+	opts := &ServerOptions{
+		Domain:     "livekit.myhost.com",
+		TURNDomain: "livekit-turn.myhost.com",
+		LocalRedis: true,
+		CloudInit:  StartupScriptCloudInitUbuntu,
+		Firewall:   FirewallUFW,
+	}
+	opts.setDefaults()
+	require.NoError(t, opts.Validate())
+
+	// the ufw script installs it with apt-get
+	opts.CloudInit = StartupScriptCloudInitRHEL
+	require.Error(t, opts.Validate())
+	opts.Firewall = FirewallFirewalld
+	require.NoError(t, opts.Validate())
+}
+
+func TestExternalRedisRequiresConnection(t *testing.T) {
+	opts := &ServerOptions{
+		Domain:     "livekit.myhost.com",
+		TURNDomain: "livekit-turn.myhost.com",
+	}
+	opts.setDefaults()
+	require.Error(t, opts.Validate())
+
+	opts.Redis.Address = "redis.myhost.com:6379"
+	require.NoError(t, opts.Validate())
+
+	opts.Redis = RedisOptions{SentinelMasterName: "livekit", SentinelAddresses: []string{"sentinel-1.myhost.com:26379"}}
+	require.NoError(t, opts.Validate())
+}
@@ -5,14 +5,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"text/template"
 
 	"github.com/google/go-github/v42/github"
 	"github.com/manifoldco/promptui"
+	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v3"
 
 	"github.com/livekit/deploy/generate/templates"
@@ -27,14 +33,171 @@ var (
 	versionRegexp = regexp.MustCompile(`^v[0-9]+(\.[0-9]+){0,2}$`)
 )
 
-func generateProduction() error {
+func generateProduction(c *cli.Context) error {
 	fmt.Println("Generating config for production LiveKit deployment")
 	fmt.Println("This deployment will utilize docker-compose and Caddy. It'll set up a secure LiveKit installation with built-in TURN/TLS")
-	fmt.Println("SSL Certificates for HTTPS and TURN/TLS will be generated automatically via LetsEncrypt or ZeroSSL.")
+	fmt.Println("SSL Certificates for HTTPS and TURN/TLS will be generated automatically via LetsEncrypt or ZeroSSL, or you can supply your own.")
 	fmt.Println()
-	opts := ServerOptions{}
+	// Redis is bundled unless requested otherwise
+	opts := ServerOptions{LocalRedis: true}
+	interactive := !c.Bool(flagNonInteractive)
+	if file := c.String(flagFromFile); file != "" {
+		if err := loadServerOptions(outputPath(file), &opts); err != nil {
+			return err
+		}
+		interactive = false
+	}
+	if err := resolveServerOptions(c, &opts, interactive); err != nil {
+		return err
+	}
+
+	baseDir := outputPath(opts.Domain)
+	if c.Bool(flagDryRun) {
+		return dryRunProduction(&opts, baseDir, nil)
+	}
+	testToken, err := resolveTestTokenOptions(c, interactive)
+	if err != nil {
+		return err
+	}
+	conf, err := generateFiles(&opts, baseDir, nil)
+	if err != nil {
+		return err
+	}
+	return printInstructions(&opts, conf, testToken)
+}
+
+// generateFiles writes all files of the deployment for the selected target, and removes stale ones.
+// The files are rendered into a temporary directory first, so that a failing check leaves baseDir untouched.
+func generateFiles(opts *ServerOptions, baseDir string, stale []string) (*config.Config, error) {
+	renderedDir, err := os.MkdirTemp("", "livekit-deploy")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(renderedDir)
+
+	conf, err := renderFiles(opts, renderedDir)
+	if err != nil {
+		return nil, err
+	}
+	// the rendered files are moved, their paths are no longer valid
+	opts.Files = ConfigFiles{}
+
+	if err = os.MkdirAll(baseDir, secretDirPerms); err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(baseDir, secretDirPerms); err != nil {
+		return nil, err
+	}
+
+	for _, name := range stale {
+		if err = os.RemoveAll(path.Join(baseDir, name)); err != nil {
+			return nil, err
+		}
+	}
+	return conf, copyRenderedFiles(renderedDir, baseDir)
+}
+
+// copyRenderedFiles copies the files rendered into renderedDir to baseDir, keeping their permissions
+func copyRenderedFiles(renderedDir, baseDir string) error {
+	return filepath.WalkDir(renderedDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(renderedDir, p)
+		if err != nil {
+			return err
+		}
+		target := path.Join(baseDir, name)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(target, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+		// WriteFile keeps the permissions of an existing file
+		return os.Chmod(target, info.Mode().Perm())
+	})
+}
+
+// renderFiles writes all files of the deployment for the selected target into dir
+func renderFiles(opts *ServerOptions, baseDir string) (*config.Config, error) {
+	assignAPIKeys(opts)
+	if opts.IsCluster() {
+		return generateCluster(opts, baseDir)
+	}
+	if err := saveServerOptions(opts, baseDir); err != nil {
+		return nil, err
+	}
+	return generateNode(opts, baseDir)
+}
+
+// generateNode writes the files of a single server
+func generateNode(opts *ServerOptions, baseDir string) (*config.Config, error) {
+	conf, err := generateLiveKit(opts, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if err = generateEgress(opts, conf, baseDir); err != nil {
+		return nil, err
+	}
+	if err = generateIngress(opts, conf, baseDir); err != nil {
+		return nil, err
+	}
+
+	switch opts.Target {
+	case TargetKubernetes:
+		if err = generateKubernetes(opts, conf, baseDir); err != nil {
+			return nil, err
+		}
+	case TargetHelm:
+		if err = generateHelm(opts, conf, baseDir); err != nil {
+			return nil, err
+		}
+	default:
+		if opts.UsesCaddy() {
+			if err = generateCaddy(opts, baseDir); err != nil {
+				return nil, err
+			}
+		}
+		switch opts.Target {
+		case TargetSystemd:
+			err = generateSystemd(opts, baseDir)
+		case TargetPodman:
+			err = generatePodman(opts, baseDir)
+		default:
+			if err = generateEnvFile(opts, conf, baseDir); err == nil {
+				err = generateDocker(opts, baseDir)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = generateFirewall(opts, conf, baseDir); err != nil {
+			return nil, err
+		}
+		if opts.CloudInit != StartupScriptNone {
+			if err = generateStartupScript(opts, conf, baseDir); err != nil {
+				return nil, err
+			}
+		}
+		if opts.Terraform {
+			if err = generateTerraform(opts, conf, baseDir); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return conf, nil
+}
 
-	// Ingress or Egress
+func selectDeployment(opts *ServerOptions) error {
 	serverSelection := promptui.Select{
 		Label: "What to deploy",
 		Items: []string{
@@ -58,23 +221,22 @@ func generateProduction() error {
 		opts.IncludeEgress = true
 		opts.IncludeIngress = true
 	}
+	return nil
+}
 
+func promptDomain(opts *ServerOptions) error {
 	prompt := promptui.Prompt{
 		Label:    "Primary domain name (i.e. livekit.myhost.com)",
 		Validate: validateDomain,
 		Stdout:   BellSkipper,
 	}
-	if opts.Domain, err = prompt.Run(); err != nil {
-		return err
-	}
-
-	// early termination here for test. TODO: move towards the end
-	baseDir := outputPath(opts.Domain)
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return err
-	}
+	var err error
+	opts.Domain, err = prompt.Run()
+	return err
+}
 
-	prompt = promptui.Prompt{
+func promptTURNDomain(opts *ServerOptions) error {
+	prompt := promptui.Prompt{
 		Label: "TURN domain name (i.e. livekit-turn.myhost.com)",
 		Validate: func(s string) error {
 			if err := validateDomain(s); err != nil {
@@ -87,37 +249,34 @@ func generateProduction() error {
 		},
 		Stdout: BellSkipper,
 	}
-	if opts.TURNDomain, err = prompt.Run(); err != nil {
-		return err
-	}
+	var err error
+	opts.TURNDomain, err = prompt.Run()
+	return err
+}
 
-	if opts.IncludeIngress {
-		prompt = promptui.Prompt{
-			Label: "Ingress WHIP domain name (optional, i.e. livekit-whip.myhost.com)",
-			Validate: func(s string) error {
-				if s == "" {
-					return nil
-				}
-				if err := validateDomain(s); err != nil {
-					return err
-				}
-				if s == opts.Domain {
-					return fmt.Errorf("cannot be same as primary domain name")
-				}
+func promptWHIPDomain(opts *ServerOptions) error {
+	prompt := promptui.Prompt{
+		Label: "Ingress WHIP domain name (optional, i.e. livekit-whip.myhost.com)",
+		Validate: func(s string) error {
+			if s == "" {
 				return nil
-			},
-			Stdout: BellSkipper,
-		}
-		if opts.WHIPDomain, err = prompt.Run(); err != nil {
-			return err
-		}
-	}
-
-	if err = selectSSLProvider(&opts); err != nil {
-		return err
+			}
+			if err := validateDomain(s); err != nil {
+				return err
+			}
+			if s == opts.Domain {
+				return fmt.Errorf("cannot be same as primary domain name")
+			}
+			return nil
+		},
+		Stdout: BellSkipper,
 	}
+	var err error
+	opts.WHIPDomain, err = prompt.Run()
+	return err
+}
 
-	// version
+func selectVersion(opts *ServerOptions) error {
 	version, err := getLatestVersion()
 	if err != nil {
 		return err
@@ -129,11 +288,10 @@ func generateProduction() error {
 		Validate: validateVersion,
 	}
 	_, opts.ServerVersion, err = versionPrompt.Run()
-	if err != nil {
-		return err
-	}
+	return err
+}
 
-	// redis
+func selectRedis(opts *ServerOptions) error {
 	redisPrompt := promptui.Select{
 		Label: "Use external Redis",
 		Items: []string{
@@ -142,23 +300,18 @@ func generateProduction() error {
 		},
 		Stdout: BellSkipper,
 	}
-	idx, _, err = redisPrompt.Run()
+	idx, _, err := redisPrompt.Run()
 	if err != nil {
 		return err
 	}
-	if idx == 0 {
-		opts.LocalRedis = true
-	}
+	opts.LocalRedis = idx == 0
+	return nil
+}
 
-	startupScripts := []StartupScriptKind{
-		StartupScriptShellScript,
-		StartupScriptCloudInitAmazon,
-		StartupScriptCloudInitUbuntu,
-		StartupScriptNone,
-	}
+func selectStartupScript(opts *ServerOptions) error {
+	kinds := opts.Target.StartupScripts()
 	var descriptions []string
-
-	for _, s := range startupScripts {
+	for _, s := range kinds {
 		descriptions = append(descriptions, s.Description())
 	}
 
@@ -168,85 +321,225 @@ func generateProduction() error {
 		Items:  descriptions,
 		Stdout: BellSkipper,
 	}
-	idx, _, err = cloudPrompt.Run()
+	idx, _, err := cloudPrompt.Run()
 	if err != nil {
 		return err
 	}
-	opts.CloudInit = startupScripts[idx]
+	opts.CloudInit = kinds[idx]
+	return nil
+}
 
-	// generate files
-	conf, err := generateLiveKit(&opts, baseDir)
-	if err != nil {
-		return err
+func selectSSLProvider(opts *ServerOptions) error {
+	if opts.SSLIssuer == "" {
+		sslPrompt := promptui.Select{
+			Label: "Which SSL issuers to use?",
+			Items: []string{
+				"Let's Encrypt (no account required)",
+				"Let's Encrypt with DNS challenge (for hosts without port 80, requires DNS provider credentials)",
+				"ZeroSSL (best compatibility, requires account)",
+				"Your own certificates (i.e. issued by an internal CA)",
+				"External load balancer terminating TLS (without Caddy)",
+			},
+			Stdout: BellSkipper,
+		}
+		idx, _, err := sslPrompt.Run()
+		if err != nil {
+			return err
+		}
+		switch idx {
+		case 0:
+			opts.SSLIssuer = SSLIssuerLetsEncrypt
+		case 1:
+			opts.SSLIssuer = SSLIssuerLetsEncrypt
+			return selectDNSProvider(opts)
+		case 2:
+			opts.SSLIssuer = SSLIssuerZeroSSL
+		case 3:
+			opts.SSLIssuer = SSLIssuerCustom
+		case 4:
+			opts.SSLIssuer = SSLIssuerExternal
+		}
 	}
-	if err = generateEgress(&opts, conf, baseDir); err != nil {
-		return err
+	if opts.IsCluster() && opts.SSLIssuer == SSLIssuerLetsEncrypt && opts.DNSProvider == "" {
+		fmt.Printf("Nodes of the cluster share %s behind the load balancer, Let's Encrypt verifies it with DNS challenges.\n", opts.Domain)
+		return selectDNSProvider(opts)
 	}
-	if err = generateIngress(&opts, conf, baseDir); err != nil {
-		return err
+	if opts.DNSProvider != "" {
+		return selectDNSProvider(opts)
 	}
-	if err = generateCaddy(&opts, baseDir); err != nil {
-		return err
+	if opts.SSLIssuer == SSLIssuerCustom {
+		return promptCertificates(opts)
 	}
-	if err = generateDocker(&opts, baseDir); err != nil {
+	if opts.SSLIssuer != SSLIssuerZeroSSL || opts.ZeroSSLAPIKey != "" {
+		return nil
+	}
+
+	prompt := promptui.Prompt{
+		Label:  "ZeroSSL API Key",
+		Stdout: BellSkipper,
+	}
+	var err error
+	if opts.ZeroSSLAPIKey, err = prompt.Run(); err != nil && err != promptui.ErrAbort {
 		return err
 	}
+	if opts.ZeroSSLAPIKey == "" {
+		// without a key, Caddy falls back to its default issuers
+		opts.SSLIssuer = SSLIssuerLetsEncrypt
+	}
+	return nil
+}
 
-	if opts.CloudInit != StartupScriptNone {
-		if err = generateStartupScript(&opts, baseDir); err != nil {
-			return err
+func promptCertificates(opts *ServerOptions) error {
+	validateFile := func(s string) error {
+		_, err := os.Stat(outputPath(s))
+		return err
+	}
+	for _, domain := range opts.Domains() {
+		cert := opts.Certificate(domain)
+		if cert == nil {
+			opts.Certificates = append(opts.Certificates, CertificateFiles{Domain: domain})
+			cert = &opts.Certificates[len(opts.Certificates)-1]
+		}
+		var err error
+		if cert.CertFile == "" {
+			prompt := promptui.Prompt{
+				Label:    fmt.Sprintf("Certificate file for %s (PEM)", domain),
+				Validate: validateFile,
+				Stdout:   BellSkipper,
+			}
+			if cert.CertFile, err = prompt.Run(); err != nil {
+				return err
+			}
+		}
+		if cert.KeyFile == "" {
+			prompt := promptui.Prompt{
+				Label:    fmt.Sprintf("Key file for %s (PEM)", domain),
+				Validate: validateFile,
+				Stdout:   BellSkipper,
+			}
+			if cert.KeyFile, err = prompt.Run(); err != nil {
+				return err
+			}
 		}
 	}
-
-	return printInstructions(&opts, conf)
+	return nil
 }
 
-func selectSSLProvider(opts *ServerOptions) error {
-	sslPrompt := promptui.Select{
-		Label: "Which SSL issuers to use?",
-		Items: []string{
-			"Let's Encrypt (no account required)",
-			"ZeroSSL (best compatibility, requires account)",
-		},
-		Stdout: BellSkipper,
+func printInstructions(opts *ServerOptions, conf *config.Config, testToken *testTokenOptions) error {
+	fmt.Println("Your production config files are generated in directory:", opts.Domain)
+	fmt.Printf("Your answers are saved to %s, run \"generate --from-file %s\" to generate them again\n",
+		path.Join(opts.Domain, deployFile), path.Join(opts.Domain, deployFile))
+	fmt.Println()
+	switch {
+	case opts.Target == TargetKubernetes:
+		printKubernetesInstructions(opts, conf)
+	case opts.Target == TargetHelm:
+		printHelmInstructions(opts, conf)
+	case opts.IsCluster():
+		printClusterInstructions(opts, conf)
+	default:
+		printHostInstructions(opts, conf)
 	}
-	idx, _, err := sslPrompt.Run()
+
+	fmt.Println()
+	fmt.Printf("Server URL: wss://%s\n", opts.Domain)
+	if opts.IncludeIngress {
+		fmt.Printf("RTMP Ingress URL: rtmp://%s/x\n", opts.Domain)
+		if opts.WHIPDomain != "" {
+			fmt.Printf("WHIP Ingress URL: https://%s/w\n", opts.WHIPDomain)
+		}
+	}
+	apiKey, apiSecret, err := getAPIKeySecret(conf, opts.APIKey)
 	if err != nil {
 		return err
 	}
-	if idx == 0 {
-		return nil
+	if len(opts.LabeledKeys) == 0 {
+		return printKeysAndToken(apiKey, apiSecret, "wss://"+opts.Domain, testToken)
 	}
+	fmt.Println()
+	printKeyTable(opts, conf)
+	fmt.Println()
+	return printTestToken(apiKey, apiSecret, "wss://"+opts.Domain, testToken)
+}
 
-	prompt := promptui.Prompt{
-		Label:  "ZeroSSL API Key",
-		Stdout: BellSkipper,
+// printKeyTable lists the labeled keys, followed by unlabeled ones left by a rotation
+func printKeyTable(opts *ServerOptions, conf *config.Config) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LABEL\tAPI KEY\tAPI SECRET\t")
+	for i, k := range opts.LabeledKeys {
+		note := ""
+		if i == 0 {
+			note = "used by Egress and Ingress"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k.Label, k.Key, conf.Keys[k.Key], note)
 	}
-	if opts.ZeroSSLAPIKey, err = prompt.Run(); err != nil && err != promptui.ErrAbort {
-		return err
+	for _, k := range sortedKeys(conf.Keys) {
+		if opts.KeyLabel(k) == "" {
+			fmt.Fprintf(w, "-\t%s\t%s\t%s\n", k, conf.Keys[k], "unlabeled, retire it with generate rotate-keys --retire")
+		}
 	}
-	return nil
+	_ = w.Flush()
 }
 
-func printInstructions(opts *ServerOptions, conf *config.Config) error {
-	fmt.Println("Your production config files are generated in directory:", opts.Domain)
-	fmt.Println()
-	fmt.Println("Please point update DNS for the following domains to the IP address of your server.")
+// printHostInstructions explains how to run the compose and systemd targets on a server
+func printHostInstructions(opts *ServerOptions, conf *config.Config) {
+	if opts.SSLIssuer == SSLIssuerExternal {
+		fmt.Println("Please point DNS for the following domains to your load balancer.")
+	} else if opts.DualStack {
+		fmt.Println("Please create A and AAAA records for the following domains, pointing to the IPv4 and IPv6 addresses of your server.")
+	} else {
+		fmt.Println("Please point update DNS for the following domains to the IP address of your server.")
+	}
 	fmt.Println(" *", opts.Domain)
 	fmt.Println(" *", opts.TURNDomain)
 	if opts.IncludeIngress && opts.WHIPDomain != "" {
 		fmt.Println(" *", opts.WHIPDomain)
 	}
+	if opts.DualStack {
+		fmt.Println()
+		fmt.Printf("LiveKit's TURN server only supports IPv4. IPv6 clients reach %s with TURN/TLS on port 443,\n", opts.TURNDomain)
+		fmt.Println("which Caddy forwards over IPv4, but TURN/UDP and the relayed traffic stay IPv4-only. Clients on")
+		fmt.Println("IPv6-only networks need NAT64 to use TURN.")
+	}
 
-	fmt.Println("Once started, Caddy will automatically acquire TLS certificates for the domains.")
+	if opts.SSLIssuer == SSLIssuerExternal {
+		fmt.Println()
+		printLoadBalancerInstructions(opts, conf)
+	} else if opts.SSLIssuer == SSLIssuerCustom {
+		fmt.Printf("Caddy will use the certificates copied to %s, replace them there when they are renewed.\n",
+			path.Join(opts.Domain, certsDir))
+	} else if opts.DNSProvider != "" {
+		fmt.Printf("Once started, Caddy will automatically acquire TLS certificates for the domains, using DNS challenges with %s.\n",
+			opts.DNSProvider)
+	} else {
+		fmt.Println("Once started, Caddy will automatically acquire TLS certificates for the domains.")
+	}
 	fmt.Println()
 	if opts.CloudInit != StartupScriptNone {
 		fmt.Printf("The file \"%s\" is a script that can be used in the \"user-data\" field when starting a new VM.\n",
 			string(opts.CloudInit))
+	} else if opts.Target == TargetSystemd {
+		fmt.Printf("You can copy the folder to %s on your server, owned by the livekit user the units run as,\n", installPrefix)
+		fmt.Println("install livekit-server and caddy to /usr/local/bin,")
+		fmt.Printf("and enable the units in %s with: \"systemctl enable --now <unit>\"\n", systemdDir)
+	} else if opts.Target == TargetPodman {
+		fmt.Printf("You can copy the folder to %s on your server, and the units in %s to /etc/containers/systemd,\n", installPrefix, quadletDir)
+		fmt.Println("then run: \"systemctl daemon-reload && systemctl start livekit\"")
+	} else if configs := containerConfigs(opts); len(configs) > 0 {
+		fmt.Printf("You can copy the folder to %s on your server, give the configs of Redis, Egress and Ingress\n", installPrefix)
+		fmt.Println("to the users of their containers, and run \"docker-compose up\":")
+		for _, c := range configs {
+			fmt.Println(" ", c.ChownCommand())
+		}
 	} else {
 		fmt.Println("You can copy the folder to your server and run: \"docker-compose up\"")
 	}
 	fmt.Println()
+	if opts.Target == TargetSystemd && opts.CloudInit == StartupScriptNone && (opts.IncludeEgress || opts.IncludeIngress) {
+		fmt.Println("Egress and Ingress are only released as container images, build them from source with GStreamer")
+		fmt.Println("to /usr/local/bin/egress and /usr/local/bin/ingress, as the shell startup script does.")
+		fmt.Println()
+	}
 
 	if opts.IncludeEgress || opts.IncludeIngress {
 		fmt.Println("Since you've enabled Egress/Ingress, we recommend running it on a machine with at least 4 cores")
@@ -254,30 +547,92 @@ func printInstructions(opts *ServerOptions, conf *config.Config) error {
 	}
 
 	fmt.Println("Please ensure the following ports are accessible on the server")
-	fmt.Println(" * 443 - primary HTTPS and TURN/TLS")
-	fmt.Println(" * 80 - for TLS issuance")
-	fmt.Printf(" * %d - for WebRTC over TCP\n", conf.RTC.TCPPort)
-	fmt.Printf(" * %d/UDP - for TURN/UDP\n", conf.TURN.UDPPort)
-	fmt.Printf(" * %d-%d/UDP - for WebRTC over UDP\n", conf.RTC.ICEPortRangeStart, conf.RTC.ICEPortRangeEnd)
-	if opts.IncludeIngress {
-		fmt.Printf(" * %d - for RTMP Ingress\n", DefaultRTMPPort)
-		fmt.Printf(" * %d/UDP - for WHIP Ingress WebRTC\n", DefaultRTCUDPPort)
+	for _, p := range hostPorts(opts, conf) {
+		fmt.Printf(" * %s - %s\n", p, p.Description)
+	}
+	if opts.Firewall != FirewallNone {
+		fmt.Printf("The startup script opens them with %s.\n", opts.Firewall)
 	}
+	fmt.Printf("%s has scripts opening them with ufw, firewalld or nftables, and rules for cloud firewalls:\n", path.Join(opts.Domain, firewallDir))
+	fmt.Printf(" * AWS: aws ec2 authorize-security-group-ingress --group-id <group> --ip-permissions file://%s\n", awsSecurityGroupFile)
+	fmt.Printf(" * GCP: NETWORK=<network> TAG=livekit ./%s\n", gcpFirewallFile)
+	fmt.Printf(" * Azure: az deployment group create --resource-group <group> --template-file %s\n", azureNSGFile)
 
-	fmt.Println()
-	fmt.Printf("Server URL: wss://%s\n", opts.Domain)
-	if opts.IncludeIngress {
-		fmt.Printf("RTMP Ingress URL: rtmp://%s/x\n", opts.Domain)
-		if opts.WHIPDomain != "" {
-			fmt.Printf("WHIP Ingress URL: https://%s/w\n", opts.WHIPDomain)
+	if opts.Terraform {
+		fmt.Println()
+		fmt.Printf("%s launches the server on AWS EC2, with an Elastic IP and a security group opening these ports.\n",
+			path.Join(opts.Domain, terraformDir, "main.tf"))
+		fmt.Println("Set route53_zone_id to create the DNS records as well:")
+		fmt.Printf(" cd %s && terraform init && terraform apply -var region=<region> [-var route53_zone_id=<zone>]\n",
+			path.Join(opts.Domain, terraformDir))
+	}
+}
+
+// hostPort is a port, or range of ports, that must be reachable on the server
+type hostPort struct {
+	Port        int
+	EndPort     int // last port of a range, 0 for a single port
+	Protocol    string
+	Description string
+}
+
+func (p hostPort) String() string {
+	s := strconv.Itoa(p.Port)
+	if p.EndPort != 0 {
+		s = fmt.Sprintf("%d-%d", p.Port, p.EndPort)
+	}
+	if p.Protocol == "udp" {
+		s += "/UDP"
+	}
+	return s
+}
+
+// Firewall is the port in the port[-end]/protocol notation of firewalld
+func (p hostPort) Firewall() string {
+	return p.Range("-") + "/" + p.Protocol
+}
+
+// Range is the port, or the first and last port of a range joined by sep
+func (p hostPort) Range(sep string) string {
+	if p.EndPort != 0 {
+		return fmt.Sprintf("%d%s%d", p.Port, sep, p.EndPort)
+	}
+	return strconv.Itoa(p.Port)
+}
+
+// LastPort is the last port of a range, or the port itself
+func (p hostPort) LastPort() int {
+	if p.EndPort != 0 {
+		return p.EndPort
+	}
+	return p.Port
+}
+
+// hostPorts lists the ports of the compose and systemd targets, where all services run on the server
+func hostPorts(opts *ServerOptions, conf *config.Config) []hostPort {
+	var ports []hostPort
+	if opts.SSLIssuer == SSLIssuerExternal {
+		for _, l := range loadBalancerListeners(opts, conf) {
+			ports = append(ports, l.Target)
+		}
+	} else {
+		ports = append(ports, hostPort{Port: 443, Protocol: "tcp", Description: "primary HTTPS and TURN/TLS"})
+		if opts.SSLIssuer != SSLIssuerCustom && opts.DNSProvider == "" {
+			ports = append(ports, hostPort{Port: 80, Protocol: "tcp", Description: "for TLS issuance"})
 		}
 	}
-	var apiKey, apiSecret string
-	for k, s := range conf.Keys {
-		apiKey = k
-		apiSecret = s
+	ports = append(ports,
+		hostPort{Port: int(conf.RTC.TCPPort), Protocol: "tcp", Description: "for WebRTC over TCP"},
+		hostPort{Port: conf.TURN.UDPPort, Protocol: "udp", Description: "for TURN/UDP"},
+		hostPort{Port: int(conf.RTC.ICEPortRangeStart), EndPort: int(conf.RTC.ICEPortRangeEnd), Protocol: "udp", Description: "for WebRTC over UDP"},
+	)
+	if opts.IncludeIngress {
+		ports = append(ports,
+			hostPort{Port: DefaultRTMPPort, Protocol: "tcp", Description: "for RTMP Ingress"},
+			hostPort{Port: DefaultRTCUDPPort, Protocol: "udp", Description: "for WHIP Ingress WebRTC"},
+		)
 	}
-	return printKeysAndToken(apiKey, apiSecret)
+	return ports
 }
 
 func validateDomain(domain string) error {
@@ -295,21 +650,35 @@ func validateVersion(version string) error {
 }
 
 func getLatestVersion() (string, error) {
+	return getLatestRelease("livekit-server")
+}
+
+// getLatestRelease returns the tag of the latest release of a LiveKit repository
+func getLatestRelease(repo string) (string, error) {
 	client := github.NewClient(nil)
-	release, _, err := client.Repositories.GetLatestRelease(context.Background(), "livekit", "livekit-server")
+	release, _, err := client.Repositories.GetLatestRelease(context.Background(), "livekit", repo)
 	if err != nil {
 		return "", err
 	}
 	return release.GetTagName(), nil
 }
 
+// generatorRelease is the release of the generator the systemd target installs on the server, the one that produced
+// the config, so its update-ip command matches the config. Development builds pin the latest release instead.
+func generatorRelease() (string, error) {
+	if strings.HasPrefix(version, "v") {
+		return version, nil
+	}
+	return getLatestRelease("deploy")
+}
+
+// ipv6LocalRanges are excluded from ICE candidates of dual-stack servers. IPv6 host candidates are used as they are,
+// without a NAT mapping like IPv4, so only the global addresses of the server can be reached by clients.
+var ipv6LocalRanges = []string{"fc00::/7", "fe80::/10"}
+
 func generateLiveKit(opts *ServerOptions, baseDir string) (*config.Config, error) {
-	apiKey := utils.NewGuid(utils.APIKeyPrefix)
-	apiSecret := utils.RandomSecret()
 	conf := config.Config{
-		Keys: map[string]string{
-			apiKey: apiSecret,
-		},
+		Keys: opts.Keys,
 		Logging: config.LoggingConfig{
 			Config: logger.Config{
 				JSON: false,
@@ -333,11 +702,26 @@ func generateLiveKit(opts *ServerOptions, baseDir string) (*config.Config, error
 			UDPPort:     3478,
 		},
 	}
+	if opts.Target.IsKubernetes() {
+		// without Caddy, LiveKit terminates TURN/TLS with the certificate issued by cert-manager
+		conf.TURN.ExternalTLS = false
+	}
+	if opts.Target == TargetKubernetes {
+		conf.TURN.CertFile = path.Join(kubernetesTURNCertDir, "tls.crt")
+		conf.TURN.KeyFile = path.Join(kubernetesTURNCertDir, "tls.key")
+	}
+	if opts.DualStack {
+		conf.RTC.IPs.Excludes = ipv6LocalRanges
+	}
 	conf.Redis = *opts.RedisConfig()
 	if opts.LocalRedis {
 		// copy redis over to basedir
+		redisConf, err := renderRedisConf(opts)
+		if err != nil {
+			return nil, err
+		}
 		opts.Files.RedisConf = path.Join(baseDir, "redis.conf")
-		if err := os.WriteFile(opts.Files.RedisConf, []byte(templates.RedisConf), filePerms); err != nil {
+		if err = writeSecretFile(opts.Files.RedisConf, []byte(redisConf)); err != nil {
 			return nil, err
 		}
 	}
@@ -351,21 +735,118 @@ func generateLiveKit(opts *ServerOptions, baseDir string) (*config.Config, error
 	}
 
 	// write config
-	data, err := yaml.Marshal(&conf)
+	fileConf := conf
+	if opts.SecretsEnv {
+		// LiveKit reads them from LIVEKIT_KEYS in .env
+		fileConf.Keys = nil
+	}
+	data, err := yaml.Marshal(&fileConf)
 	if err != nil {
 		return nil, err
 	}
+	if opts.existingLiveKit != nil {
+		if data, err = mergeLiveKitConfig(opts, data); err != nil {
+			return nil, err
+		}
+	}
 	opts.Files.LiveKit = path.Join(baseDir, "livekit.yaml")
-	return &conf, os.WriteFile(opts.Files.LiveKit, data, filePerms)
+	return &conf, writeSecretFile(opts.Files.LiveKit, data)
+}
+
+func selectKeyLabels(opts *ServerOptions) error {
+	keysPrompt := promptui.Select{
+		Label:  "API keys",
+		Items:  []string{"A single API key", "Separate API keys with labels, i.e. one per product"},
+		Stdout: BellSkipper,
+	}
+	idx, _, err := keysPrompt.Run()
+	if err != nil || idx == 0 {
+		return err
+	}
+
+	prompt := promptui.Prompt{
+		Label: "Key labels, comma separated, Egress and Ingress use the first one (i.e. service,product-a,product-b)",
+		Validate: func(s string) error {
+			_, err := parseKeyLabels(s)
+			return err
+		},
+		Stdout: BellSkipper,
+	}
+	value, err := prompt.Run()
+	if err != nil {
+		return err
+	}
+	labels, err := parseKeyLabels(value)
+	if err != nil {
+		return err
+	}
+	opts.setKeyLabels(labels)
+	return nil
+}
+
+// parseKeyLabels reads comma separated key labels
+func parseKeyLabels(value string) ([]string, error) {
+	var labels []string
+	for _, label := range strings.Split(value, ",") {
+		label = strings.TrimSpace(label)
+		if !keyLabelRegexp.MatchString(label) {
+			return nil, fmt.Errorf("invalid key label %q", label)
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+// assignAPIKeys generates the keys of a new deployment, and those of labels without a key yet
+func assignAPIKeys(opts *ServerOptions) {
+	if opts.Keys == nil {
+		opts.Keys = make(map[string]string)
+	}
+	if len(opts.LabeledKeys) > 0 && opts.Keys[opts.LabeledKeys[0].Key] == "" {
+		// an existing deployment keeps the key of Egress and Ingress, as the first label
+		current, _, err := getAPIKeySecret(&config.Config{Keys: opts.Keys}, opts.APIKey)
+		if err == nil && opts.KeyLabel(current) == "" {
+			opts.LabeledKeys[0].Key = current
+		}
+	}
+	for i, k := range opts.LabeledKeys {
+		if _, ok := opts.Keys[k.Key]; ok {
+			continue
+		}
+		for key, secret := range newAPIKeys() {
+			opts.Keys[key] = secret
+			opts.LabeledKeys[i].Key = key
+		}
+	}
+	if len(opts.Keys) == 0 {
+		opts.Keys = newAPIKeys()
+	}
+	if len(opts.LabeledKeys) > 0 {
+		opts.APIKey = opts.LabeledKeys[0].Key
+	}
+	if _, ok := opts.Keys[opts.APIKey]; !ok {
+		// answers of another deployment, i.e. replayed with --from-file, name a key that was replaced
+		opts.APIKey = ""
+	}
+}
+
+func newAPIKeys() map[string]string {
+	return map[string]string{
+		utils.NewGuid(utils.APIKeyPrefix): utils.RandomSecret(),
+	}
 }
 
 func generateCaddy(opts *ServerOptions, baseDir string) error {
+	if err := copyCertificates(opts, baseDir); err != nil {
+		return err
+	}
 	tmpl, err := template.New("caddy").Parse(templates.CaddyConfigTemplate)
 	if err != nil {
 		return err
 	}
 	opts.Files.Caddy = path.Join(baseDir, "caddy.yaml")
-	f, err := os.Create(opts.Files.Caddy)
+	// the ZeroSSL API key and DNS credentials are part of it
+	f, err := createSecretFile(opts.Files.Caddy)
 	if err != nil {
 		return err
 	}
@@ -373,6 +854,35 @@ func generateCaddy(opts *ServerOptions, baseDir string) error {
 	return tmpl.Execute(f, opts)
 }
 
+// copyCertificates copies the user supplied certificates next to caddy.yaml, named after their domain
+func copyCertificates(opts *ServerOptions, baseDir string) error {
+	opts.Files.Certificates = nil
+	if len(opts.Certificates) == 0 {
+		return nil
+	}
+	dir := path.Join(baseDir, certsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, cert := range opts.Certificates {
+		for _, f := range []struct{ src, name string }{
+			{cert.CertFile, cert.Domain + ".crt"},
+			{cert.KeyFile, cert.Domain + ".key"},
+		} {
+			data, err := os.ReadFile(outputPath(f.src))
+			if err != nil {
+				return err
+			}
+			target := path.Join(dir, f.name)
+			if err = writeSecretFile(target, data); err != nil {
+				return err
+			}
+			opts.Files.Certificates = append(opts.Files.Certificates, target)
+		}
+	}
+	return nil
+}
+
 func generateDocker(opts *ServerOptions, baseDir string) error {
 	tmpl, err := template.New("docker").Parse(templates.DockerComposeBaseTemplate)
 	if err != nil {
@@ -431,14 +941,32 @@ func prefixLines(input string, prefix string) string {
 	return output
 }
 
-func getAPIKeySecret(conf *config.Config) (string, string, error) {
-	var apiKey, apiSecret string
-	for k, s := range conf.Keys {
-		apiKey = k
-		apiSecret = s
+// getAPIKeySecret returns apiKey and its secret, or the only key in conf when apiKey is empty.
+// Which of several keys to use can't be guessed, rotations keep the old key next to the new one.
+func getAPIKeySecret(conf *config.Config, apiKey string) (string, string, error) {
+	if apiKey != "" {
+		apiSecret, ok := conf.Keys[apiKey]
+		if !ok {
+			return "", "", fmt.Errorf("api key %s not found in config", apiKey)
+		}
+		return apiKey, apiSecret, nil
 	}
-	if apiKey == "" {
+	switch len(conf.Keys) {
+	case 0:
 		return "", "", errors.New("no api key found in config")
+	case 1:
+		for k, s := range conf.Keys {
+			return k, s, nil
+		}
+	}
+	return "", "", fmt.Errorf("several api keys found in config, select one of %s", strings.Join(sortedKeys(conf.Keys), ", "))
+}
+
+func sortedKeys(keys map[string]string) []string {
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
 	}
-	return apiKey, apiSecret, nil
+	sort.Strings(sorted)
+	return sorted
 }
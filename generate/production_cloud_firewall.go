@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/livekit/deploy/generate/templates"
+)
+
+// files in firewallDir opening the ports in the network firewalls of cloud providers
+const (
+	awsSecurityGroupFile = "aws_security_group.json"
+	gcpFirewallFile      = "gcp_firewall.sh"
+	azureNSGFile         = "azure_nsg.json"
+)
+
+// cloudResourceName names the resources created for a deployment at cloud providers
+func cloudResourceName(domain string) string {
+	return "livekit-" + strings.ReplaceAll(domain, ".", "-")
+}
+
+// IpPermissions of aws ec2 authorize-security-group-ingress
+// https://docs.aws.amazon.com/cli/latest/reference/ec2/authorize-security-group-ingress.html
+
+type awsIPPermission struct {
+	IpProtocol string         `json:"IpProtocol"`
+	FromPort   int            `json:"FromPort"`
+	ToPort     int            `json:"ToPort"`
+	IpRanges   []awsIPRange   `json:"IpRanges"`
+	Ipv6Ranges []awsIPv6Range `json:"Ipv6Ranges"`
+}
+
+type awsIPRange struct {
+	CidrIp      string `json:"CidrIp"`
+	Description string `json:"Description"`
+}
+
+type awsIPv6Range struct {
+	CidrIpv6    string `json:"CidrIpv6"`
+	Description string `json:"Description"`
+}
+
+// ARM template of a network security group
+// https://learn.microsoft.com/en-us/azure/templates/microsoft.network/networksecuritygroups
+
+type armTemplate struct {
+	Schema         string                  `json:"$schema"`
+	ContentVersion string                  `json:"contentVersion"`
+	Parameters     map[string]armParameter `json:"parameters"`
+	Resources      []armResource           `json:"resources"`
+}
+
+type armParameter struct {
+	Type         string `json:"type"`
+	DefaultValue string `json:"defaultValue"`
+}
+
+type armResource struct {
+	Type       string        `json:"type"`
+	APIVersion string        `json:"apiVersion"`
+	Name       string        `json:"name"`
+	Location   string        `json:"location"`
+	Properties azureNSGProps `json:"properties"`
+}
+
+type azureNSGProps struct {
+	SecurityRules []azureSecurityRule `json:"securityRules"`
+}
+
+type azureSecurityRule struct {
+	Name       string             `json:"name"`
+	Properties azureSecurityProps `json:"properties"`
+}
+
+type azureSecurityProps struct {
+	Description              string `json:"description"`
+	Protocol                 string `json:"protocol"`
+	SourcePortRange          string `json:"sourcePortRange"`
+	DestinationPortRange     string `json:"destinationPortRange"`
+	SourceAddressPrefix      string `json:"sourceAddressPrefix"`
+	DestinationAddressPrefix string `json:"destinationAddressPrefix"`
+	Access                   string `json:"access"`
+	Priority                 int    `json:"priority"`
+	Direction                string `json:"direction"`
+}
+
+// generateCloudFirewalls writes the ports as AWS security group rules, a gcloud script and an Azure NSG
+func generateCloudFirewalls(opts *ServerOptions, ports []hostPort, dir string) error {
+	var permissions []awsIPPermission
+	var rules []azureSecurityRule
+	for i, p := range ports {
+		permissions = append(permissions, awsIPPermission{
+			IpProtocol: p.Protocol,
+			FromPort:   p.Port,
+			ToPort:     p.LastPort(),
+			IpRanges:   []awsIPRange{{CidrIp: "0.0.0.0/0", Description: p.Description}},
+			Ipv6Ranges: []awsIPv6Range{{CidrIpv6: "::/0", Description: p.Description}},
+		})
+		rules = append(rules, azureSecurityRule{
+			Name: fmt.Sprintf("livekit-%d-%s", p.Port, p.Protocol),
+			Properties: azureSecurityProps{
+				Description:              p.Description,
+				Protocol:                 strings.ToUpper(p.Protocol[:1]) + p.Protocol[1:],
+				SourcePortRange:          "*",
+				DestinationPortRange:     p.Range("-"),
+				SourceAddressPrefix:      "*",
+				DestinationAddressPrefix: "*",
+				Access:                   "Allow",
+				Priority:                 1000 + 10*i,
+				Direction:                "Inbound",
+			},
+		})
+	}
+	if err := writeJSON(path.Join(dir, awsSecurityGroupFile), permissions); err != nil {
+		return err
+	}
+
+	nsg := &armTemplate{
+		Schema:         "https://schema.management.azure.com/schemas/2019-04-01/deploymentTemplate.json#",
+		ContentVersion: "1.0.0.0",
+		Parameters: map[string]armParameter{
+			"name": {Type: "string", DefaultValue: cloudResourceName(opts.Domain)},
+		},
+		Resources: []armResource{{
+			Type:       "Microsoft.Network/networkSecurityGroups",
+			APIVersion: "2023-05-01",
+			Name:       "[parameters('name')]",
+			Location:   "[resourceGroup().location]",
+			Properties: azureNSGProps{SecurityRules: rules},
+		}},
+	}
+	if err := writeJSON(path.Join(dir, azureNSGFile), nsg); err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("gcp").Parse(templates.GCPFirewallTemplate)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path.Join(dir, gcpFirewallFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return tmpl.Execute(f, &struct {
+		Name      string
+		Ports     []hostPort
+		DualStack bool
+	}{Name: cloudResourceName(opts.Domain), Ports: ports, DualStack: opts.DualStack})
+}
+
+func writeJSON(target string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, append(data, '\n'), filePerms)
+}
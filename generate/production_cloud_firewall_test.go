@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCloudFirewalls(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	conf, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+	ports := hostPorts(opts, conf)
+	require.NotEmpty(t, ports)
+
+	data, err := os.ReadFile(path.Join(dir, firewallDir, awsSecurityGroupFile))
+	require.NoError(t, err)
+	var permissions []awsIPPermission
+	require.NoError(t, json.Unmarshal(data, &permissions))
+	require.Len(t, permissions, len(ports))
+	for i, p := range ports {
+		require.Equal(t, p.Protocol, permissions[i].IpProtocol)
+		require.Equal(t, p.Port, permissions[i].FromPort)
+		require.Equal(t, p.LastPort(), permissions[i].ToPort)
+		require.Equal(t, []awsIPRange{{CidrIp: "0.0.0.0/0", Description: p.Description}}, permissions[i].IpRanges)
+		require.Equal(t, []awsIPv6Range{{CidrIpv6: "::/0", Description: p.Description}}, permissions[i].Ipv6Ranges)
+	}
+
+	data, err = os.ReadFile(path.Join(dir, firewallDir, azureNSGFile))
+	require.NoError(t, err)
+	nsg := &armTemplate{}
+	require.NoError(t, json.Unmarshal(data, nsg))
+	require.Equal(t, "livekit-livekit-myhost-com", nsg.Parameters["name"].DefaultValue)
+	require.Len(t, nsg.Resources, 1)
+	require.Equal(t, "Microsoft.Network/networkSecurityGroups", nsg.Resources[0].Type)
+	rules := nsg.Resources[0].Properties.SecurityRules
+	require.Len(t, rules, len(ports))
+	names := make(map[string]bool)
+	priorities := make(map[int]bool)
+	for i, p := range ports {
+		require.Equal(t, p.Range("-"), rules[i].Properties.DestinationPortRange)
+		require.Contains(t, []string{"Tcp", "Udp"}, rules[i].Properties.Protocol)
+		require.Equal(t, "Inbound", rules[i].Properties.Direction)
+		// both have to be unique within the security group
+		names[rules[i].Name] = true
+		priorities[rules[i].Properties.Priority] = true
+	}
+	require.Len(t, names, len(ports))
+	require.Len(t, priorities, len(ports))
+
+	info, err := os.Stat(path.Join(dir, firewallDir, gcpFirewallFile))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// clusterSummaryFile lists the DNS records and load balancer entries a cluster needs
+const clusterSummaryFile = "cluster.txt"
+
+// IsCluster is true when the deployment is spread over multiple nodes
+func (o *ServerOptions) IsCluster() bool {
+	return len(o.Nodes) != 0
+}
+
+// NodeName is the directory and DNS label used for a node given by hostname or IP
+func NodeName(node string) string {
+	if net.ParseIP(node) != nil {
+		return strings.NewReplacer(".", "-", ":", "-").Replace(node)
+	}
+	name, _, _ := strings.Cut(node, ".")
+	return name
+}
+
+// NodeTURNDomain is the TURN domain of a node. TURN connections must reach the node the participant
+// is connected to, so each node gets its own domain below the TURN domain.
+func (o *ServerOptions) NodeTURNDomain(node string) string {
+	return NodeName(node) + "." + o.TURNDomain
+}
+
+// nodeOptions derives the options of a single node in the cluster
+func (o *ServerOptions) nodeOptions(node string) *ServerOptions {
+	nodeOpts := *o
+	nodeOpts.Nodes = nil
+	nodeOpts.TURNDomain = o.NodeTURNDomain(node)
+	nodeOpts.Files = ConfigFiles{}
+	// only copy the certificates of the node's own domains
+	nodeOpts.Certificates = nil
+	for _, domain := range nodeOpts.Domains() {
+		if cert := o.Certificate(domain); cert != nil {
+			nodeOpts.Certificates = append(nodeOpts.Certificates, *cert)
+		}
+	}
+	return &nodeOpts
+}
+
+// parseNodes reads a comma separated list of node hostnames or IPs, or a number of nodes to name node1 to nodeN
+func parseNodes(s string) ([]string, error) {
+	if count, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return numberedNodes(count)
+	}
+	return splitList(s), nil
+}
+
+func numberedNodes(count int) ([]string, error) {
+	if count < 2 {
+		return nil, errors.New("a cluster requires at least 2 nodes")
+	}
+	nodes := make([]string, count)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("node%d", i+1)
+	}
+	return nodes, nil
+}
+
+func validateNodes(nodes []string) error {
+	if len(nodes) < 2 {
+		return errors.New("a cluster requires at least 2 nodes")
+	}
+	names := make(map[string]string)
+	for _, node := range nodes {
+		if net.ParseIP(node) == nil && !domainRegexp.MatchString(node+".") {
+			return fmt.Errorf("node %s: requires a hostname or IP address", node)
+		}
+		name := NodeName(node)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("nodes %s and %s would share the name %s", other, node, name)
+		}
+		names[name] = node
+	}
+	return nil
+}
+
+func selectTopology(opts *ServerOptions) error {
+	topologyPrompt := promptui.Select{
+		Label: "Deployment topology",
+		Items: []string{
+			"Single server",
+			"Multi-node cluster (requires external Redis)",
+		},
+		Stdout: BellSkipper,
+	}
+	idx, _, err := topologyPrompt.Run()
+	if err != nil || idx == 0 {
+		return err
+	}
+
+	prompt := promptui.Prompt{
+		Label: "Node hostnames or IPs, comma separated, or the number of nodes",
+		Validate: func(s string) error {
+			nodes, err := parseNodes(s)
+			if err != nil {
+				return err
+			}
+			return validateNodes(nodes)
+		},
+		Stdout: BellSkipper,
+	}
+	value, err := prompt.Run()
+	if err != nil {
+		return err
+	}
+	opts.Nodes, err = parseNodes(value)
+	return err
+}
+
+// generateCluster writes a directory for each node, all sharing the same API keys and Redis
+func generateCluster(opts *ServerOptions, baseDir string) (*config.Config, error) {
+	if err := saveServerOptions(opts, baseDir); err != nil {
+		return nil, err
+	}
+
+	var conf *config.Config
+	for _, node := range opts.Nodes {
+		nodeDir := path.Join(baseDir, NodeName(node))
+		if err := os.MkdirAll(nodeDir, 0755); err != nil {
+			return nil, err
+		}
+		nodeConf, err := generateNode(opts.nodeOptions(node), nodeDir)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", node, err)
+		}
+		if conf == nil {
+			conf = nodeConf
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	writeClusterSummary(buf, opts, conf)
+	return conf, os.WriteFile(path.Join(baseDir, clusterSummaryFile), buf.Bytes(), filePerms)
+}
+
+// writeClusterSummary lists the DNS records and load balancer entries needed by the cluster
+func writeClusterSummary(w io.Writer, opts *ServerOptions, conf *config.Config) {
+	fmt.Fprintf(w, "LiveKit cluster of %d nodes for %s\n\n", len(opts.Nodes), opts.Domain)
+
+	fmt.Fprintln(w, "Load balancer, forwarding TCP without terminating TLS to every node:")
+	fmt.Fprintln(w, " * 443 - HTTPS and WebSocket, Caddy on the nodes terminates TLS")
+	if opts.IncludeIngress {
+		fmt.Fprintf(w, " * %d - RTMP Ingress\n", DefaultRTMPPort)
+	}
+	fmt.Fprintln(w, " Targets:")
+	for _, node := range opts.Nodes {
+		fmt.Fprintln(w, "  *", node)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "DNS records:")
+	fmt.Fprintf(w, " * %s -> load balancer\n", opts.Domain)
+	if opts.IncludeIngress && opts.WHIPDomain != "" {
+		fmt.Fprintf(w, " * %s -> load balancer\n", opts.WHIPDomain)
+	}
+	for _, node := range opts.Nodes {
+		fmt.Fprintf(w, " * %s -> %s\n", opts.NodeTURNDomain(node), nodeRecord(node, opts.DualStack))
+	}
+	if opts.DualStack {
+		fmt.Fprintln(w, " The load balancer needs a public IPv6 address as well, with AAAA records next to the A records.")
+		fmt.Fprintln(w, " TURN stays IPv4-only: IPv6 clients reach it with TURN/TLS on 443, TURN/UDP and relays use IPv4.")
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Ports that must be accessible on every node, without the load balancer:")
+	fmt.Fprintln(w, " * 443 - TURN/TLS")
+	fmt.Fprintf(w, " * %d - for WebRTC over TCP\n", conf.RTC.TCPPort)
+	fmt.Fprintf(w, " * %d/UDP - for TURN/UDP\n", conf.TURN.UDPPort)
+	fmt.Fprintf(w, " * %d-%d/UDP - for WebRTC over UDP\n", conf.RTC.ICEPortRangeStart, conf.RTC.ICEPortRangeEnd)
+	if opts.IncludeIngress {
+		fmt.Fprintf(w, " * %d/UDP - for WHIP Ingress WebRTC\n", DefaultRTCUDPPort)
+	}
+	fmt.Fprintf(w, "The %s directory of each node has scripts and cloud firewall rules opening them, with 443 and the load balanced ports.\n", firewallDir)
+}
+
+// nodeRecord describes the DNS record pointing to a node
+func nodeRecord(node string, dualStack bool) string {
+	ip := net.ParseIP(node)
+	switch {
+	case ip != nil && ip.To4() != nil:
+		return "A " + node
+	case ip != nil:
+		return "AAAA " + node
+	case strings.Contains(node, "."):
+		return "CNAME " + node
+	case dualStack:
+		return fmt.Sprintf("A and AAAA with the public IPv4 and IPv6 of %s", node)
+	default:
+		return fmt.Sprintf("A with the public IP of %s", node)
+	}
+}
+
+func printClusterInstructions(opts *ServerOptions, conf *config.Config) {
+	writeClusterSummary(os.Stdout, opts, conf)
+	fmt.Printf("This summary is saved to %s\n", path.Join(opts.Domain, clusterSummaryFile))
+	fmt.Println()
+
+	fmt.Println("Each node has its own directory, all nodes share the API keys and must be able to reach Redis at", opts.RedisConfig().Address)
+	if opts.CloudInit != StartupScriptNone {
+		fmt.Printf("The file \"%s\" in each node's directory can be used in the \"user-data\" field when starting the node.\n",
+			string(opts.CloudInit))
+	} else if opts.Target == TargetSystemd {
+		fmt.Printf("You can copy each node's folder to the node, and enable the units in %s with: \"systemctl enable --now <unit>\"\n", systemdDir)
+	} else if opts.Target == TargetPodman {
+		fmt.Printf("You can copy each node's folder to %s on the node, and the units in %s to /etc/containers/systemd\n", installPrefix, quadletDir)
+	} else {
+		fmt.Println("You can copy each node's folder to the node and run: \"docker-compose up\"")
+	}
+}
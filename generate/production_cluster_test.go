@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNodes(t *testing.T) {
+	nodes, err := parseNodes("3")
+	require.NoError(t, err)
+	require.Equal(t, []string{"node1", "node2", "node3"}, nodes)
+
+	nodes, err = parseNodes("10.0.0.1, lk2.internal,")
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1", "lk2.internal"}, nodes)
+	require.NoError(t, validateNodes(nodes))
+	require.Equal(t, "10-0-0-1", NodeName(nodes[0]))
+	require.Equal(t, "lk2", NodeName(nodes[1]))
+
+	require.Error(t, validateNodes([]string{"lk1.a.com", "lk1.b.com"}))
+	_, err = parseNodes("1")
+	require.Error(t, err)
+}
+
+func TestClusterCertificates(t *testing.T) {
+	opts := &ServerOptions{
+		Domain:     "livekit.myhost.com",
+		TURNDomain: "livekit-turn.myhost.com",
+		Nodes:      []string{"node1", "node2"},
+		Redis:      RedisOptions{Address: "redis.myhost.com:6379"},
+	}
+	opts.setDefaults()
+	// HTTP challenges for the shared domain can reach any node
+	require.Error(t, opts.Validate())
+
+	opts.DNSProvider = "cloudflare"
+	require.NoError(t, opts.Validate())
+}
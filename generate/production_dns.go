@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+)
+
+// dnsProvider is a caddy-dns module that can solve ACME DNS-01 challenges
+type dnsProvider struct {
+	Name        string
+	Description string
+	// Credentials are the fields of the provider's Caddy config
+	Credentials []string
+}
+
+// the livekit/caddyl4 image is published with a variant for each of these providers
+var dnsProviders = []dnsProvider{
+	{
+		Name:        "cloudflare",
+		Description: "Cloudflare",
+		Credentials: []string{"api_token"},
+	},
+	{
+		Name:        "route53",
+		Description: "AWS Route53",
+		Credentials: []string{"access_key_id", "secret_access_key", "region"},
+	},
+	{
+		Name:        "digitalocean",
+		Description: "DigitalOcean",
+		Credentials: []string{"auth_token"},
+	},
+}
+
+func getDNSProvider(name string) (*dnsProvider, error) {
+	for i := range dnsProviders {
+		if dnsProviders[i].Name == name {
+			return &dnsProviders[i], nil
+		}
+	}
+	var names []string
+	for _, p := range dnsProviders {
+		names = append(names, p.Name)
+	}
+	return nil, fmt.Errorf("unknown DNS provider %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+func selectDNSProvider(opts *ServerOptions) error {
+	if opts.DNSProvider == "" {
+		var descriptions []string
+		for _, p := range dnsProviders {
+			descriptions = append(descriptions, p.Description)
+		}
+		providerPrompt := promptui.Select{
+			Label:  "DNS provider hosting your domains",
+			Items:  descriptions,
+			Stdout: BellSkipper,
+		}
+		idx, _, err := providerPrompt.Run()
+		if err != nil {
+			return err
+		}
+		opts.DNSProvider = dnsProviders[idx].Name
+	}
+
+	provider, err := getDNSProvider(opts.DNSProvider)
+	if err != nil {
+		return err
+	}
+	if opts.DNSCredentials == nil {
+		opts.DNSCredentials = make(map[string]string)
+	}
+	for _, field := range provider.Credentials {
+		if opts.DNSCredentials[field] != "" {
+			continue
+		}
+		prompt := promptui.Prompt{
+			Label:  fmt.Sprintf("%s %s", provider.Description, field),
+			Stdout: BellSkipper,
+		}
+		value, err := prompt.Run()
+		if err != nil && err != promptui.ErrAbort {
+			return err
+		}
+		if value != "" {
+			opts.DNSCredentials[field] = value
+		}
+	}
+	return nil
+}
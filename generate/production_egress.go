@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"path"
 
 	"gopkg.in/yaml.v3"
@@ -24,15 +23,15 @@ func generateEgress(opts *ServerOptions, lkConf *config.Config, baseDir string)
 	if !opts.IncludeEgress {
 		return nil
 	}
-	egressConf := &egressConfig{}
-	apiKey, apiSecret, err := getAPIKeySecret(lkConf)
+	egressConf, err := newEgressConfig(opts, lkConf)
 	if err != nil {
 		return err
 	}
-	egressConf.ApiKey = apiKey
-	egressConf.ApiSecret = apiSecret
-	egressConf.WsUrl = fmt.Sprintf("wss://%s", opts.Domain)
-	egressConf.Redis = opts.RedisConfig()
+	if opts.SecretsEnv {
+		// read from LIVEKIT_API_KEY and LIVEKIT_API_SECRET in .env
+		egressConf.ApiKey = ""
+		egressConf.ApiSecret = ""
+	}
 
 	// write config
 	data, err := yaml.Marshal(&egressConf)
@@ -40,5 +39,18 @@ func generateEgress(opts *ServerOptions, lkConf *config.Config, baseDir string)
 		return err
 	}
 	opts.Files.Egress = path.Join(baseDir, "egress.yaml")
-	return os.WriteFile(opts.Files.Egress, data, filePerms)
+	return writeSecretFile(opts.Files.Egress, data)
+}
+
+func newEgressConfig(opts *ServerOptions, lkConf *config.Config) (*egressConfig, error) {
+	egressConf := &egressConfig{}
+	apiKey, apiSecret, err := getAPIKeySecret(lkConf, opts.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	egressConf.ApiKey = apiKey
+	egressConf.ApiSecret = apiSecret
+	egressConf.WsUrl = fmt.Sprintf("wss://%s", opts.Domain)
+	egressConf.Redis = opts.RedisConfig()
+	return egressConf, nil
 }
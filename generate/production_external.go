@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// loadBalancerListener is a TLS listener of the external load balancer, forwarding the decrypted stream to the server
+type loadBalancerListener struct {
+	Domain      string
+	Target      hostPort
+	Description string
+}
+
+// loadBalancerListeners are what Caddy would otherwise do, when TLS is terminated by an external load balancer
+func loadBalancerListeners(opts *ServerOptions, conf *config.Config) []loadBalancerListener {
+	listeners := []loadBalancerListener{
+		{
+			Domain:      opts.Domain,
+			Target:      hostPort{Port: int(conf.Port), Protocol: "tcp", Description: "HTTP and WebSocket, from the load balancer"},
+			Description: "API and WebSocket signaling, the listener must allow WebSocket upgrades",
+		},
+		{
+			Domain:      opts.TURNDomain,
+			Target:      hostPort{Port: conf.TURN.TLSPort, Protocol: "tcp", Description: "TURN/TLS decrypted by the load balancer"},
+			Description: "TURN/TLS, plain TCP after decryption",
+		},
+	}
+	if opts.IncludeIngress && opts.WHIPDomain != "" {
+		listeners = append(listeners, loadBalancerListener{
+			Domain:      opts.WHIPDomain,
+			Target:      hostPort{Port: DefaultWHIPPort, Protocol: "tcp", Description: "WHIP Ingress, from the load balancer"},
+			Description: "WHIP Ingress",
+		})
+	}
+	return listeners
+}
+
+func printLoadBalancerInstructions(opts *ServerOptions, conf *config.Config) {
+	fmt.Println("Your load balancer terminates TLS with certificates for the domains, and forwards TCP to the server:")
+	for _, l := range loadBalancerListeners(opts, conf) {
+		fmt.Printf(" * %s:443 (TLS) -> %s - %s\n", l.Domain, l.Target, l.Description)
+	}
+	fmt.Println("The listeners share port 443, route them by SNI (i.e. F5), or give each domain its own load balancer address (i.e. AWS NLB).")
+	fmt.Println("PROXY protocol must be disabled on the listeners, LiveKit doesn't accept it.")
+	fmt.Println("WebRTC, TURN/UDP and RTMP are not load balanced, their ports must be reachable on the server directly.")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestLoadBalancerListeners(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.SSLIssuer = SSLIssuerExternal
+	require.NoError(t, opts.Validate())
+	_, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+	require.NoFileExists(t, path.Join(dir, "caddy.yaml"))
+
+	data, err := os.ReadFile(opts.Files.LiveKit)
+	require.NoError(t, err)
+	conf := &config.Config{}
+	require.NoError(t, yaml.Unmarshal(data, conf))
+	// the load balancer decrypts TURN/TLS
+	require.True(t, conf.TURN.ExternalTLS)
+
+	listeners := loadBalancerListeners(opts, conf)
+	require.Len(t, listeners, 3)
+	targets := make(map[string]hostPort)
+	for _, l := range listeners {
+		require.Equal(t, "tcp", l.Target.Protocol)
+		targets[l.Domain] = l.Target
+	}
+	require.Equal(t, int(conf.Port), targets[opts.Domain].Port)
+	require.Equal(t, conf.TURN.TLSPort, targets[opts.TURNDomain].Port)
+	require.Equal(t, DefaultWHIPPort, targets[opts.WHIPDomain].Port)
+
+	// the server opens the targets instead of 443 and 80
+	ports := hostPorts(opts, conf)
+	for _, l := range listeners {
+		require.Contains(t, ports, l.Target)
+	}
+	for _, p := range ports {
+		require.NotEqual(t, 443, p.Port)
+		require.NotEqual(t, 80, p.Port)
+	}
+
+	data, err = os.ReadFile(opts.Files.Docker)
+	require.NoError(t, err)
+	compose := struct {
+		Services map[string]interface{} `yaml:"services"`
+	}{}
+	require.NoError(t, yaml.Unmarshal(data, &compose))
+	require.NotContains(t, compose.Services, "caddy")
+	require.Contains(t, compose.Services, "livekit")
+
+	// WHIP is only listened for with Ingress
+	opts.IncludeIngress = false
+	require.Len(t, loadBalancerListeners(opts, conf), 2)
+}
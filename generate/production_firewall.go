@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path"
+	"text/template"
+
+	"github.com/manifoldco/promptui"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// firewallDir holds a script for each supported firewall, opening the ports of the server
+const firewallDir = "firewall"
+
+// generateFirewall writes the scripts of all firewalls, so that any of them can be applied by hand
+func generateFirewall(opts *ServerOptions, conf *config.Config, baseDir string) error {
+	dir := path.Join(baseDir, firewallDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	content := struct{ Ports []hostPort }{Ports: hostPorts(opts, conf)}
+	if err := generateCloudFirewalls(opts, content.Ports, dir); err != nil {
+		return err
+	}
+	for _, kind := range firewallKinds {
+		tmpl, err := template.New(string(kind)).Parse(kind.Template())
+		if err != nil {
+			return err
+		}
+		target := path.Join(dir, kind.Script())
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+		err = tmpl.Execute(f, &content)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if kind == opts.Firewall {
+			opts.Files.Firewall = target
+		}
+	}
+	return nil
+}
+
+func selectFirewall(opts *ServerOptions) error {
+	items := []string{"none"}
+	for _, kind := range opts.CloudInit.Firewalls() {
+		items = append(items, string(kind))
+	}
+	firewallPrompt := promptui.Select{
+		Label:  "Firewall to open the ports with, from the startup script",
+		Items:  items,
+		Stdout: BellSkipper,
+	}
+	idx, _, err := firewallPrompt.Run()
+	if err != nil {
+		return err
+	}
+	if idx != 0 {
+		opts.Firewall = FirewallKind(items[idx])
+	}
+	return nil
+}
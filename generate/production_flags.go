@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagNonInteractive        = "non-interactive"
+	flagFromFile              = "from-file"
+	flagDryRun                = "dry-run"
+	flagDomain                = "domain"
+	flagTURNDomain            = "turn-domain"
+	flagWHIPDomain            = "whip-domain"
+	flagEgress                = "egress"
+	flagIngress               = "ingress"
+	flagSSLIssuer             = "ssl-issuer"
+	flagZeroSSLAPIKey         = "zerossl-api-key"
+	flagTLSCert               = "tls-cert"
+	flagTLSKey                = "tls-key"
+	flagDNSProvider           = "dns-provider"
+	flagDNSCredential         = "dns-credential"
+	flagServerVersion         = "server-version"
+	flagExternalRedis         = "external-redis"
+	flagRedisAddress          = "redis-address"
+	flagRedisUsername         = "redis-username"
+	flagRedisPassword         = "redis-password"
+	flagRedisDB               = "redis-db"
+	flagRedisTLS              = "redis-tls"
+	flagRedisMaster           = "redis-sentinel-master"
+	flagRedisSentinels        = "redis-sentinel-addresses"
+	flagRedisSentinelPassword = "redis-sentinel-password"
+	flagStartupScript         = "startup-script"
+	flagFirewall              = "firewall"
+	flagTarget                = "target"
+	flagNodes                 = "nodes"
+	flagTerraform             = "terraform"
+	flagNodeCount             = "node-count"
+	flagDualStack             = "dual-stack"
+	flagKeyLabels             = "key-labels"
+	flagSecretsEnv            = "secrets-env"
+)
+
+// productionFlags expose every ServerOptions field, values that are not supplied are prompted for
+var productionFlags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:  flagNonInteractive,
+		Usage: "do not prompt, fail if a required value has not been supplied",
+	},
+	&cli.BoolFlag{
+		Name:  flagDryRun,
+		Usage: "print a diff of the files that would be generated, without writing them",
+	},
+	&cli.StringFlag{
+		Name:  flagFromFile,
+		Usage: "read answers from a deploy.yaml written by a previous run, implies --non-interactive",
+	},
+	&cli.StringFlag{
+		Name:  flagDomain,
+		Usage: "primary domain name (i.e. livekit.myhost.com)",
+	},
+	&cli.StringFlag{
+		Name:  flagTURNDomain,
+		Usage: "TURN domain name (i.e. livekit-turn.myhost.com)",
+	},
+	&cli.StringFlag{
+		Name:  flagWHIPDomain,
+		Usage: "Ingress WHIP domain name (optional, i.e. livekit-whip.myhost.com)",
+	},
+	&cli.BoolFlag{
+		Name:  flagEgress,
+		Usage: "deploy Egress",
+	},
+	&cli.BoolFlag{
+		Name:  flagIngress,
+		Usage: "deploy Ingress",
+	},
+	&cli.StringFlag{
+		Name:  flagSSLIssuer,
+		Usage: "SSL issuer to use, one of letsencrypt, zerossl, custom or external (TLS terminated by a load balancer, without Caddy)",
+	},
+	&cli.StringFlag{
+		Name:  flagZeroSSLAPIKey,
+		Usage: "ZeroSSL API Key, implies --ssl-issuer zerossl",
+	},
+	&cli.StringSliceFlag{
+		Name:  flagTLSCert,
+		Usage: "certificate file for a domain as domain=path, implies --ssl-issuer custom",
+	},
+	&cli.StringSliceFlag{
+		Name:  flagTLSKey,
+		Usage: "certificate key file for a domain as domain=path",
+	},
+	&cli.StringFlag{
+		Name:  flagDNSProvider,
+		Usage: "solve ACME challenges over DNS, for hosts without port 80. One of cloudflare, route53 or digitalocean",
+	},
+	&cli.StringSliceFlag{
+		Name:  flagDNSCredential,
+		Usage: "credential for the DNS provider as field=value, i.e. api_token=...",
+	},
+	&cli.StringFlag{
+		Name:  flagServerVersion,
+		Usage: "LiveKit version, latest or a release (i.e. v1.4.3)",
+	},
+	&cli.BoolFlag{
+		Name:  flagExternalRedis,
+		Usage: "use an external Redis instead of bundling one",
+	},
+	&cli.StringFlag{
+		Name:  flagRedisAddress,
+		Usage: "address of the external Redis as host:port, implies --external-redis",
+	},
+	&cli.StringFlag{
+		Name:  flagRedisUsername,
+		Usage: "username for the external Redis",
+	},
+	&cli.StringFlag{
+		Name:  flagRedisPassword,
+		Usage: "password for Redis, a random one is generated for the bundled Redis when omitted",
+	},
+	&cli.IntFlag{
+		Name:  flagRedisDB,
+		Usage: "DB index in the external Redis",
+	},
+	&cli.BoolFlag{
+		Name:  flagRedisTLS,
+		Usage: "connect to the external Redis with TLS",
+	},
+	&cli.StringFlag{
+		Name:  flagRedisMaster,
+		Usage: "Redis Sentinel master name, implies --external-redis",
+	},
+	&cli.StringSliceFlag{
+		Name:  flagRedisSentinels,
+		Usage: "Redis Sentinel addresses as host:port",
+	},
+	&cli.StringFlag{
+		Name:  flagRedisSentinelPassword,
+		Usage: "Redis Sentinel password",
+	},
+	&cli.StringFlag{
+		Name:  flagStartupScript,
+		Usage: "startup script to generate, one of shell, amazon, amazon2023, ubuntu, debian, rhel, ignition or none",
+	},
+	&cli.StringFlag{
+		Name:  flagFirewall,
+		Usage: "firewall the startup script opens the ports with, one of ufw, firewalld or nftables",
+	},
+	&cli.StringFlag{
+		Name:  flagTarget,
+		Usage: "runtime to generate the deployment for, one of compose, systemd, podman, kubernetes or helm",
+		Value: string(TargetCompose),
+	},
+	&cli.BoolFlag{
+		Name:  flagTerraform,
+		Usage: "write a Terraform configuration launching the server on AWS EC2, with the startup script as user data",
+	},
+	&cli.StringSliceFlag{
+		Name:  flagNodes,
+		Usage: "generate a cluster with a node for each hostname or IP, implies --external-redis",
+	},
+	&cli.IntFlag{
+		Name:  flagNodeCount,
+		Usage: "generate a cluster of nodes named node1 to nodeN, implies --external-redis",
+	},
+	&cli.BoolFlag{
+		Name:  flagDualStack,
+		Usage: "serve clients over IPv6 as well as IPv4, the server needs a public IPv6 address. TURN stays IPv4-only: IPv6 clients reach it with TURN/TLS on port 443, and its relays and TURN/UDP use IPv4",
+	},
+	&cli.BoolFlag{
+		Name:  flagSecretsEnv,
+		Usage: "write the API keys to a .env file used by docker-compose, instead of livekit.yaml, egress.yaml and ingress.yaml",
+	},
+	&cli.StringSliceFlag{
+		Name:  flagKeyLabels,
+		Usage: "labels of separate API keys, i.e. one per product sharing the server. Egress and Ingress use the first one",
+	},
+}
+
+// resolveServerOptions applies values supplied as flags to opts, and prompts for the rest when interactive
+func resolveServerOptions(c *cli.Context, opts *ServerOptions, interactive bool) error {
+	var err error
+
+	if c.IsSet(flagTarget) {
+		opts.Target = DeploymentTarget(c.String(flagTarget))
+	}
+
+	// Ingress or Egress
+	if c.IsSet(flagEgress) {
+		opts.IncludeEgress = c.Bool(flagEgress)
+	}
+	if c.IsSet(flagIngress) {
+		opts.IncludeIngress = c.Bool(flagIngress)
+	}
+	if interactive && !c.IsSet(flagEgress) && !c.IsSet(flagIngress) {
+		if err = selectDeployment(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagDomain) {
+		opts.Domain = c.String(flagDomain)
+	} else if interactive {
+		if err = promptDomain(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagTURNDomain) {
+		opts.TURNDomain = c.String(flagTURNDomain)
+	} else if interactive {
+		if err = promptTURNDomain(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagWHIPDomain) {
+		opts.WHIPDomain = c.String(flagWHIPDomain)
+	} else if interactive && opts.IncludeIngress {
+		if err = promptWHIPDomain(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagNodes) {
+		opts.Nodes = c.StringSlice(flagNodes)
+	} else if c.IsSet(flagNodeCount) {
+		if opts.Nodes, err = numberedNodes(c.Int(flagNodeCount)); err != nil {
+			return err
+		}
+	} else if interactive && !opts.Target.IsKubernetes() {
+		if err = selectTopology(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagSecretsEnv) {
+		opts.SecretsEnv = c.Bool(flagSecretsEnv)
+	}
+	if c.IsSet(flagDualStack) {
+		opts.DualStack = c.Bool(flagDualStack)
+	}
+
+	if c.IsSet(flagSSLIssuer) {
+		opts.SSLIssuer = SSLIssuer(c.String(flagSSLIssuer))
+	}
+	if c.IsSet(flagZeroSSLAPIKey) {
+		if opts.SSLIssuer == "" {
+			opts.SSLIssuer = SSLIssuerZeroSSL
+		}
+		opts.ZeroSSLAPIKey = c.String(flagZeroSSLAPIKey)
+	}
+	if c.IsSet(flagTLSCert) || c.IsSet(flagTLSKey) {
+		if opts.SSLIssuer == "" {
+			opts.SSLIssuer = SSLIssuerCustom
+		}
+		if err = setCertificateFlags(opts, c.StringSlice(flagTLSCert), c.StringSlice(flagTLSKey)); err != nil {
+			return err
+		}
+	}
+	if c.IsSet(flagDNSProvider) {
+		if opts.SSLIssuer == "" {
+			opts.SSLIssuer = SSLIssuerLetsEncrypt
+		}
+		opts.DNSProvider = c.String(flagDNSProvider)
+	}
+	if c.IsSet(flagDNSCredential) {
+		if opts.DNSCredentials == nil {
+			opts.DNSCredentials = make(map[string]string)
+		}
+		for _, v := range c.StringSlice(flagDNSCredential) {
+			field, value, ok := strings.Cut(v, "=")
+			if !ok || field == "" {
+				return fmt.Errorf("invalid DNS credential %q, expected field=value", v)
+			}
+			opts.DNSCredentials[field] = value
+		}
+	}
+	if interactive && !opts.Target.IsKubernetes() {
+		if err = selectSSLProvider(opts); err != nil {
+			return err
+		}
+	} else if interactive && opts.DNSProvider != "" {
+		// cert-manager solves the challenges of the TURN domain with the provider
+		if err = selectDNSProvider(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagServerVersion) {
+		opts.ServerVersion = c.String(flagServerVersion)
+	} else if interactive {
+		if err = selectVersion(opts); err != nil {
+			return err
+		}
+	}
+
+	externalRedis := c.IsSet(flagRedisAddress) || c.IsSet(flagRedisMaster) || c.IsSet(flagRedisSentinels)
+	wasLocalRedis := opts.LocalRedis
+	if c.IsSet(flagExternalRedis) {
+		opts.LocalRedis = !c.Bool(flagExternalRedis)
+	} else if externalRedis || opts.Target == TargetHelm || opts.IsCluster() {
+		opts.LocalRedis = false
+	} else if interactive {
+		if err = selectRedis(opts); err != nil {
+			return err
+		}
+	}
+	if opts.LocalRedis && !wasLocalRedis {
+		// the connection and credentials of the external Redis don't apply to the bundled one
+		opts.Redis = RedisOptions{}
+	}
+	setRedisFlags(c, &opts.Redis)
+	if interactive && !opts.LocalRedis && !externalRedis && opts.Redis.Address == "" && !opts.Redis.IsSentinel() {
+		if err = promptRedis(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagStartupScript) {
+		if opts.CloudInit, err = CloudInitFromName(c.String(flagStartupScript)); err != nil {
+			return err
+		}
+	} else if interactive && !opts.Target.IsKubernetes() {
+		if err = selectStartupScript(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagFirewall) {
+		opts.Firewall = FirewallKind(c.String(flagFirewall))
+	} else if interactive && opts.CloudInit != "" && opts.CloudInit != StartupScriptNone {
+		if err = selectFirewall(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagTerraform) {
+		opts.Terraform = c.Bool(flagTerraform)
+	} else if interactive && opts.CloudInit != "" && opts.CloudInit != StartupScriptNone && !opts.IsCluster() {
+		if err = selectTerraform(opts); err != nil {
+			return err
+		}
+	}
+
+	if c.IsSet(flagKeyLabels) {
+		var labels []string
+		for _, v := range c.StringSlice(flagKeyLabels) {
+			parsed, err := parseKeyLabels(v)
+			if err != nil {
+				return err
+			}
+			labels = append(labels, parsed...)
+		}
+		opts.setKeyLabels(labels)
+	} else if interactive && len(opts.LabeledKeys) == 0 {
+		if err = selectKeyLabels(opts); err != nil {
+			return err
+		}
+	}
+
+	opts.setDefaults()
+	return opts.Validate()
+}
+
+// setRedisFlags applies the Redis connection flags to r
+func setRedisFlags(c *cli.Context, r *RedisOptions) {
+	if c.IsSet(flagRedisAddress) {
+		r.Address = c.String(flagRedisAddress)
+	}
+	if c.IsSet(flagRedisUsername) {
+		r.Username = c.String(flagRedisUsername)
+	}
+	if c.IsSet(flagRedisPassword) {
+		r.Password = c.String(flagRedisPassword)
+	}
+	if c.IsSet(flagRedisDB) {
+		r.DB = c.Int(flagRedisDB)
+	}
+	if c.IsSet(flagRedisTLS) {
+		r.UseTLS = c.Bool(flagRedisTLS)
+	}
+	if c.IsSet(flagRedisMaster) {
+		r.SentinelMasterName = c.String(flagRedisMaster)
+	}
+	if c.IsSet(flagRedisSentinels) {
+		r.SentinelAddresses = c.StringSlice(flagRedisSentinels)
+	}
+	if c.IsSet(flagRedisSentinelPassword) {
+		r.SentinelPassword = c.String(flagRedisSentinelPassword)
+	}
+}
+
+// setCertificateFlags applies domain=path values of the certificate flags to opts
+func setCertificateFlags(opts *ServerOptions, certs, keys []string) error {
+	for _, flag := range []struct {
+		values []string
+		key    bool
+	}{{certs, false}, {keys, true}} {
+		for _, v := range flag.values {
+			domain, file, ok := strings.Cut(v, "=")
+			if !ok || domain == "" || file == "" {
+				return fmt.Errorf("invalid certificate %q, expected domain=path", v)
+			}
+			cert := opts.Certificate(domain)
+			if cert == nil {
+				opts.Certificates = append(opts.Certificates, CertificateFiles{Domain: domain})
+				cert = &opts.Certificates[len(opts.Certificates)-1]
+			}
+			if flag.key {
+				cert.KeyFile = file
+			} else {
+				cert.CertFile = file
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	helmServerValuesFile  = "livekit-server-values.yaml"
+	helmEgressValuesFile  = "egress-values.yaml"
+	helmIngressValuesFile = "ingress-values.yaml"
+)
+
+// subset of the chart values in https://github.com/livekit/livekit-helm
+// the livekit, egress and ingress sections are passed through to the services as their config
+
+type helmServerValues struct {
+	ReplicaCount int                    `yaml:"replicaCount"`
+	LiveKit      map[string]interface{} `yaml:"livekit"`
+	LoadBalancer helmLoadBalancer       `yaml:"loadBalancer"`
+}
+
+type helmEgressValues struct {
+	ReplicaCount int           `yaml:"replicaCount"`
+	Egress       *egressConfig `yaml:"egress"`
+}
+
+type helmIngressValues struct {
+	ReplicaCount int              `yaml:"replicaCount"`
+	Ingress      *ingressConfig   `yaml:"ingress"`
+	LoadBalancer helmLoadBalancer `yaml:"loadBalancer,omitempty"`
+}
+
+type helmLoadBalancer struct {
+	Type string    `yaml:"type,omitempty"`
+	TLS  []helmTLS `yaml:"tls,omitempty"`
+}
+
+type helmTLS struct {
+	Hosts      []string `yaml:"hosts"`
+	SecretName string   `yaml:"secretName"`
+}
+
+// generateHelm translates the generated configs into values files for the livekit-server, egress and ingress charts
+func generateHelm(opts *ServerOptions, lkConf *config.Config, baseDir string) error {
+	livekit, err := toValuesMap(lkConf)
+	if err != nil {
+		return err
+	}
+	if turn, ok := livekit["turn"].(map[string]interface{}); ok {
+		// the chart mounts the TURN/TLS certificate from this secret, and exposes TURN with its own service
+		turn["secretName"] = "livekit-turn-tls"
+		turn["serviceType"] = "LoadBalancer"
+	}
+	serverValues := &helmServerValues{
+		ReplicaCount: 1,
+		LiveKit:      livekit,
+		LoadBalancer: helmLoadBalancer{
+			// set to the load balancer of your cloud provider, i.e. aws, gke or do
+			Type: "disable",
+			TLS: []helmTLS{{
+				Hosts:      []string{opts.Domain},
+				SecretName: "livekit-tls",
+			}},
+		},
+	}
+	if err = writeHelmValues(path.Join(baseDir, helmServerValuesFile), "livekit-server", serverValues); err != nil {
+		return err
+	}
+
+	if opts.IncludeEgress {
+		egressConf, err := newEgressConfig(opts, lkConf)
+		if err != nil {
+			return err
+		}
+		values := &helmEgressValues{
+			ReplicaCount: 1,
+			Egress:       egressConf,
+		}
+		if err = writeHelmValues(path.Join(baseDir, helmEgressValuesFile), "egress", values); err != nil {
+			return err
+		}
+	}
+
+	if opts.IncludeIngress {
+		ingressConf, err := newIngressConfig(opts, lkConf)
+		if err != nil {
+			return err
+		}
+		values := &helmIngressValues{
+			ReplicaCount: 1,
+			Ingress:      ingressConf,
+		}
+		if opts.WHIPDomain != "" {
+			values.LoadBalancer = helmLoadBalancer{
+				Type: "disable",
+				TLS: []helmTLS{{
+					Hosts:      []string{opts.WHIPDomain},
+					SecretName: "livekit-whip-tls",
+				}},
+			}
+		}
+		if err = writeHelmValues(path.Join(baseDir, helmIngressValuesFile), "ingress", values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHelmValues(file, chart string, values interface{}) error {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("# values for the livekit/%s chart, install with:\n# helm install %s livekit/%s -f %s\n",
+		chart, chart, chart, path.Base(file))
+	return writeSecretFile(file, append([]byte(header), data...))
+}
+
+// toValuesMap round trips a config through yaml, so that chart specific fields can be added to it
+func toValuesMap(v interface{}) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	if err = yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func printHelmInstructions(opts *ServerOptions, conf *config.Config) {
+	fmt.Println("The values files can be used to install LiveKit with Helm")
+	fmt.Println(" helm repo add livekit https://helm.livekit.io")
+	fmt.Printf(" helm install livekit-server livekit/livekit-server -f %s\n", path.Join(opts.Domain, helmServerValuesFile))
+	if opts.IncludeEgress {
+		fmt.Printf(" helm install egress livekit/egress -f %s\n", path.Join(opts.Domain, helmEgressValuesFile))
+	}
+	if opts.IncludeIngress {
+		fmt.Printf(" helm install ingress livekit/ingress -f %s\n", path.Join(opts.Domain, helmIngressValuesFile))
+	}
+	fmt.Println()
+	fmt.Println("Set loadBalancer.type to match your cloud provider, and create the TLS secrets referenced in the values,")
+	fmt.Println("or issue them with cert-manager.")
+	fmt.Println()
+
+	fmt.Println("Please update DNS for the following domains to the load balancers created by the charts.")
+	fmt.Println(" *", opts.Domain)
+	fmt.Println(" *", opts.TURNDomain)
+	if opts.IncludeIngress && opts.WHIPDomain != "" {
+		fmt.Println(" *", opts.WHIPDomain)
+	}
+	fmt.Println()
+
+	fmt.Println("Please ensure the following ports are accessible on the nodes")
+	fmt.Printf(" * %d - for WebRTC over TCP\n", conf.RTC.TCPPort)
+	fmt.Printf(" * %d-%d/UDP - for WebRTC over UDP\n", conf.RTC.ICEPortRangeStart, conf.RTC.ICEPortRangeEnd)
+	if opts.IncludeIngress {
+		fmt.Printf(" * %d - for RTMP Ingress\n", DefaultRTMPPort)
+		fmt.Printf(" * %d/UDP - for WHIP Ingress WebRTC\n", DefaultRTCUDPPort)
+	}
+}
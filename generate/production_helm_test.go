@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateHelm(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.Target = TargetHelm
+	opts.LocalRedis = false
+	opts.Redis.Address = "redis.myhost.com:6379"
+	require.NoError(t, opts.Validate())
+	conf, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path.Join(dir, helmServerValuesFile))
+	require.NoError(t, err)
+	server := &helmServerValues{}
+	require.NoError(t, yaml.Unmarshal(data, server))
+	require.Equal(t, 1, server.ReplicaCount)
+	require.Equal(t, []string{opts.Domain}, server.LoadBalancer.TLS[0].Hosts)
+	turn, ok := server.LiveKit["turn"].(map[string]interface{})
+	require.True(t, ok)
+	require.Equal(t, "livekit-turn-tls", turn["secretName"])
+	require.Equal(t, opts.TURNDomain, turn["domain"])
+	require.Equal(t, opts.Redis.Address, server.LiveKit["redis"].(map[string]interface{})["address"])
+
+	data, err = os.ReadFile(path.Join(dir, helmEgressValuesFile))
+	require.NoError(t, err)
+	egress := &helmEgressValues{}
+	require.NoError(t, yaml.Unmarshal(data, egress))
+	require.Equal(t, opts.Redis.Address, egress.Egress.Redis.Address)
+	require.Contains(t, conf.Keys, egress.Egress.ApiKey)
+
+	data, err = os.ReadFile(path.Join(dir, helmIngressValuesFile))
+	require.NoError(t, err)
+	ingress := &helmIngressValues{}
+	require.NoError(t, yaml.Unmarshal(data, ingress))
+	require.Equal(t, []string{opts.WHIPDomain}, ingress.LoadBalancer.TLS[0].Hosts)
+	require.Contains(t, conf.Keys, ingress.Ingress.ApiKey)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path"
+	"text/template"
+
+	"github.com/livekit/deploy/generate/templates"
+)
+
+const (
+	ignitionVersion = "3.3.0"
+	// Flatcar mounts /usr read-only, binaries that are not part of the image go to /opt/bin
+	ignitionComposePath = "/opt/bin/docker-compose"
+	// completed with the architecture of the host, i.e. x86_64 or aarch64
+	ignitionComposeURL = "https://github.com/docker/compose/releases/download/v2.20.2/docker-compose-linux"
+	// quadlet units are read from here on Fedora CoreOS
+	ignitionQuadletDir = "/etc/containers/systemd"
+)
+
+// subset of the Ignition config spec v3.3.0, supported by Fedora CoreOS and Flatcar
+// https://coreos.github.io/ignition/configuration-v3_3/
+
+type ignitionConfig struct {
+	Ignition ignitionMeta    `json:"ignition"`
+	Storage  ignitionStorage `json:"storage"`
+	Systemd  ignitionSystemd `json:"systemd"`
+}
+
+type ignitionMeta struct {
+	Version string `json:"version"`
+}
+
+type ignitionStorage struct {
+	Directories []ignitionDirectory `json:"directories,omitempty"`
+	Files       []ignitionFile      `json:"files"`
+}
+
+type ignitionDirectory struct {
+	Path string `json:"path"`
+	Mode int    `json:"mode"`
+}
+
+type ignitionFile struct {
+	Path      string           `json:"path"`
+	Mode      int              `json:"mode"`
+	Overwrite bool             `json:"overwrite"`
+	Contents  ignitionResource `json:"contents"`
+}
+
+type ignitionResource struct {
+	Source string `json:"source"`
+}
+
+type ignitionSystemd struct {
+	Units []ignitionUnit `json:"units"`
+}
+
+type ignitionUnit struct {
+	Name     string `json:"name"`
+	Enabled  bool   `json:"enabled"`
+	Contents string `json:"contents"`
+}
+
+// ignitionContent renders the scripts and units only used by the Ignition config
+type ignitionContent struct {
+	InstallPrefix string
+	ComposeURL    string
+	ComposePath   string
+}
+
+// generateIgnition embeds the generated files into an Ignition config, with the units that run them at boot
+func generateIgnition(opts *ServerOptions, baseDir string) error {
+	content := &ignitionContent{
+		InstallPrefix: installPrefix,
+		ComposeURL:    ignitionComposeURL,
+		ComposePath:   ignitionComposePath,
+	}
+	ign := &ignitionConfig{
+		Ignition: ignitionMeta{Version: ignitionVersion},
+		Storage: ignitionStorage{
+			// the configs hold API secrets and passwords, only root can enter the install directory
+			Directories: []ignitionDirectory{
+				{Path: installPrefix, Mode: 0700},
+				{Path: path.Join(installPrefix, "caddy_data"), Mode: 0755},
+			},
+		},
+	}
+
+	// Redis, Egress and Ingress run as unprivileged users, their services give them their configs before starting
+	configs := []struct {
+		file string
+		mode int
+	}{
+		{opts.Files.LiveKit, secretFilePerms},
+		{opts.Files.Caddy, secretFilePerms},
+		{opts.Files.Env, secretFilePerms},
+		{opts.Files.Docker, filePerms},
+		{opts.Files.Egress, secretFilePerms},
+		{opts.Files.Ingress, secretFilePerms},
+	}
+	if opts.LocalRedis {
+		configs = append(configs, struct {
+			file string
+			mode int
+		}{opts.Files.RedisConf, secretFilePerms})
+	}
+	for _, c := range configs {
+		if c.file == "" {
+			continue
+		}
+		if err := ign.addFile(c.file, path.Join(installPrefix, path.Base(c.file)), c.mode); err != nil {
+			return err
+		}
+	}
+	for _, file := range opts.Files.Certificates {
+		if err := ign.addFile(file, path.Join(installPrefix, certsDir, path.Base(file)), 0600); err != nil {
+			return err
+		}
+	}
+	if opts.UsesCaddy() {
+		updateIP, err := updateIPScript(opts.Target)
+		if err != nil {
+			return err
+		}
+		ign.Storage.Files = append(ign.Storage.Files, ignitionFile{
+			Path:      path.Join(installPrefix, "update_ip.sh"),
+			Mode:      0755,
+			Overwrite: true,
+			Contents:  dataURL([]byte(updateIP)),
+		})
+		if err = ign.addUnit("livekit-update-ip.service", templates.IgnitionUpdateIPUnit, content); err != nil {
+			return err
+		}
+	}
+	if opts.Files.Firewall != "" {
+		if err := ign.addFile(opts.Files.Firewall, path.Join(installPrefix, "firewall.sh"), 0755); err != nil {
+			return err
+		}
+		if err := ign.addUnit("livekit-firewall.service", templates.IgnitionFirewallUnit, content); err != nil {
+			return err
+		}
+	}
+
+	if opts.Target == TargetPodman {
+		// quadlet generates and starts the services from the units at boot
+		for _, file := range opts.Files.Units {
+			if err := ign.addFile(file, path.Join(ignitionQuadletDir, path.Base(file)), 0644); err != nil {
+				return err
+			}
+		}
+	} else {
+		// Ignition can't pick the binary for the architecture, the install script does at boot
+		installScript, err := renderIgnitionTemplate(templates.IgnitionComposeScript, content)
+		if err != nil {
+			return err
+		}
+		ign.Storage.Files = append(ign.Storage.Files, ignitionFile{
+			Path:      path.Join(installPrefix, "install_compose.sh"),
+			Mode:      0755,
+			Overwrite: true,
+			Contents:  dataURL([]byte(installScript)),
+		})
+		if err = ign.addUnit("livekit-compose-install.service", templates.IgnitionComposeUnit, content); err != nil {
+			return err
+		}
+
+		tmpl, err := template.New("systemd").Parse(templates.SystemdServiceTemplate)
+		if err != nil {
+			return err
+		}
+		buf := bytes.Buffer{}
+		err = tmpl.Execute(&buf, &cloudInitContent{
+			InstallPrefix:    installPrefix,
+			DockerCompose:    ignitionComposePath,
+			ContainerConfigs: containerConfigs(opts),
+		})
+		if err != nil {
+			return err
+		}
+		ign.Systemd.Units = append(ign.Systemd.Units, ignitionUnit{
+			Name:     "livekit-docker.service",
+			Enabled:  true,
+			Contents: buf.String(),
+		})
+	}
+
+	data, err := json.MarshalIndent(ign, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSecretFile(path.Join(baseDir, string(StartupScriptIgnition)), append(data, '\n'))
+}
+
+func (c *ignitionConfig) addFile(src, target string, mode int) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	c.Storage.Files = append(c.Storage.Files, ignitionFile{
+		Path:      target,
+		Mode:      mode,
+		Overwrite: true,
+		Contents:  dataURL(data),
+	})
+	return nil
+}
+
+func (c *ignitionConfig) addUnit(name, unitTemplate string, content *ignitionContent) error {
+	unit, err := renderIgnitionTemplate(unitTemplate, content)
+	if err != nil {
+		return err
+	}
+	c.Systemd.Units = append(c.Systemd.Units, ignitionUnit{
+		Name:     name,
+		Enabled:  true,
+		Contents: unit,
+	})
+	return nil
+}
+
+func renderIgnitionTemplate(text string, content *ignitionContent) (string, error) {
+	tmpl, err := template.New("ignition").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.Buffer{}
+	if err = tmpl.Execute(&buf, content); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func dataURL(data []byte) ignitionResource {
+	return ignitionResource{Source: "data:;base64," + base64.StdEncoding.EncodeToString(data)}
+}
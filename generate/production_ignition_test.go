@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIgnition(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.CloudInit = StartupScriptIgnition
+	opts.Firewall = FirewallNftables
+	_, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path.Join(dir, string(StartupScriptIgnition)))
+	require.NoError(t, err)
+	ign := &ignitionConfig{}
+	require.NoError(t, json.Unmarshal(data, ign))
+	require.Equal(t, ignitionVersion, ign.Ignition.Version)
+	require.Equal(t, ignitionDirectory{Path: installPrefix, Mode: 0700}, ign.Storage.Directories[0])
+
+	modes := make(map[string]int)
+	for _, f := range ign.Storage.Files {
+		modes[f.Path] = f.Mode
+		require.True(t, strings.HasPrefix(f.Contents.Source, "data:;base64,"), f.Path)
+		_, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(f.Contents.Source, "data:;base64,"))
+		require.NoError(t, err, f.Path)
+	}
+	require.Equal(t, map[string]int{
+		"/opt/livekit/livekit.yaml":        0600,
+		"/opt/livekit/caddy.yaml":          0600,
+		"/opt/livekit/docker-compose.yaml": 0644,
+		"/opt/livekit/egress.yaml":         0600,
+		"/opt/livekit/ingress.yaml":        0600,
+		"/opt/livekit/redis.conf":          0600,
+		"/opt/livekit/update_ip.sh":        0755,
+		"/opt/livekit/firewall.sh":         0755,
+		"/opt/livekit/install_compose.sh":  0755,
+	}, modes)
+
+	var units []string
+	for _, u := range ign.Systemd.Units {
+		units = append(units, u.Name)
+		require.NotContains(t, u.Contents, "{{", u.Name)
+		if u.Name == "livekit-docker.service" {
+			// the configs of the containers are given to their users before starting them
+			require.Contains(t, u.Contents, "\nExecStartPre="+containerConfigs(opts)[0].ExecStartPre()+"\n")
+		}
+		if u.Name != "livekit-docker.service" {
+			// the scripts the units run are embedded in the install directory
+			require.Contains(t, u.Contents, "\nExecStart="+installPrefix+"/", u.Name)
+		}
+	}
+	require.ElementsMatch(t, []string{
+		"livekit-update-ip.service",
+		"livekit-firewall.service",
+		"livekit-compose-install.service",
+		"livekit-docker.service",
+	}, units)
+}
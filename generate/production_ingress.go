@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"path"
 
 	"gopkg.in/yaml.v3"
@@ -39,12 +38,31 @@ func generateIngress(opts *ServerOptions, lkConf *config.Config, baseDir string)
 	if !opts.IncludeIngress {
 		return nil
 	}
+	ingressConf, err := newIngressConfig(opts, lkConf)
+	if err != nil {
+		return err
+	}
+	if opts.SecretsEnv {
+		// read from LIVEKIT_API_KEY and LIVEKIT_API_SECRET in .env
+		ingressConf.ApiKey = ""
+		ingressConf.ApiSecret = ""
+	}
 
-	ingressConf := &ingressConfig{}
-	apiKey, apiSecret, err := getAPIKeySecret(lkConf)
+	// write config
+	data, err := yaml.Marshal(ingressConf)
 	if err != nil {
 		return err
 	}
+	opts.Files.Ingress = path.Join(baseDir, "ingress.yaml")
+	return writeSecretFile(opts.Files.Ingress, data)
+}
+
+func newIngressConfig(opts *ServerOptions, lkConf *config.Config) (*ingressConfig, error) {
+	ingressConf := &ingressConfig{}
+	apiKey, apiSecret, err := getAPIKeySecret(lkConf, opts.APIKey)
+	if err != nil {
+		return nil, err
+	}
 	ingressConf.ApiKey = apiKey
 	ingressConf.ApiSecret = apiSecret
 	ingressConf.WsUrl = fmt.Sprintf("wss://%s", opts.Domain)
@@ -54,12 +72,5 @@ func generateIngress(opts *ServerOptions, lkConf *config.Config, baseDir string)
 	ingressConf.HTTPRelayPort = DefaultHTTPRelayPort
 	ingressConf.RTCConfig.UDPPort = DefaultRTCUDPPort
 	ingressConf.RTCConfig.UseExternalIP = true
-
-	// write config
-	data, err := yaml.Marshal(ingressConf)
-	if err != nil {
-		return err
-	}
-	opts.Files.Ingress = path.Join(baseDir, "ingress.yaml")
-	return os.WriteFile(opts.Files.Ingress, data, filePerms)
+	return ingressConf, nil
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/deploy/generate/templates"
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	kubernetesNamespace   = "livekit"
+	kubernetesTURNCertDir = "/etc/livekit-turn"
+)
+
+type kubernetesContent struct {
+	Namespace     string
+	Domain        string
+	TURNDomain    string
+	WHIPDomain    string
+	ServerVersion string
+	APIKey        string
+	APISecret     string
+	LiveKitKeys   string // all keys of the server as a quoted YAML string, the active one and those being rotated out
+	TURNCertDir   string
+
+	// DNS-01 challenges of the TURN certificate
+	DNSProvider    string
+	DNSCredentials map[string]string
+
+	LiveKitConfig string
+	RedisConf     string
+	EgressConfig  string
+	IngressConfig string
+
+	Port           uint32
+	RTCTCPPort     uint32
+	TURNTLSPort    int
+	TURNUDPPort    int
+	RTMPPort       int
+	WHIPPort       int
+	IngressUDPPort int
+}
+
+// generateKubernetes writes the manifest for the kubernetes target. The configs hold Redis credentials and are
+// Secrets, API keys are kept out of them and provided to the pods through the livekit-keys Secret instead.
+func generateKubernetes(opts *ServerOptions, lkConf *config.Config, baseDir string) error {
+	apiKey, apiSecret, err := getAPIKeySecret(lkConf, opts.APIKey)
+	if err != nil {
+		return err
+	}
+	content := kubernetesContent{
+		Namespace:      kubernetesNamespace,
+		Domain:         opts.Domain,
+		TURNDomain:     opts.TURNDomain,
+		WHIPDomain:     opts.WHIPDomain,
+		ServerVersion:  opts.ServerVersion,
+		APIKey:         apiKey,
+		APISecret:      apiSecret,
+		LiveKitKeys:    strconv.Quote(liveKitKeys(lkConf.Keys)),
+		TURNCertDir:    kubernetesTURNCertDir,
+		DNSProvider:    opts.DNSProvider,
+		DNSCredentials: opts.DNSCredentials,
+		Port:           lkConf.Port,
+		RTCTCPPort:     lkConf.RTC.TCPPort,
+		TURNTLSPort:    lkConf.TURN.TLSPort,
+		TURNUDPPort:    lkConf.TURN.UDPPort,
+		RTMPPort:       DefaultRTMPPort,
+		WHIPPort:       DefaultWHIPPort,
+		IngressUDPPort: DefaultRTCUDPPort,
+	}
+
+	// four space indent for Secret data
+	indent := "    "
+	conf := *lkConf
+	conf.Keys = nil
+	if content.LiveKitConfig, err = marshalAndPrefix(&conf, indent); err != nil {
+		return err
+	}
+	if opts.LocalRedis {
+		redisConf, err := renderRedisConf(opts)
+		if err != nil {
+			return err
+		}
+		content.RedisConf = prefixLines(redisConf, indent)
+	}
+	if opts.IncludeEgress {
+		egressConf, err := newEgressConfig(opts, lkConf)
+		if err != nil {
+			return err
+		}
+		egressConf.ApiKey = ""
+		egressConf.ApiSecret = ""
+		if content.EgressConfig, err = marshalAndPrefix(egressConf, indent); err != nil {
+			return err
+		}
+	}
+	if opts.IncludeIngress {
+		ingressConf, err := newIngressConfig(opts, lkConf)
+		if err != nil {
+			return err
+		}
+		ingressConf.ApiKey = ""
+		ingressConf.ApiSecret = ""
+		if content.IngressConfig, err = marshalAndPrefix(ingressConf, indent); err != nil {
+			return err
+		}
+	}
+
+	buf := bytes.Buffer{}
+	manifests := []string{templates.KubernetesTemplate}
+	if opts.LocalRedis {
+		manifests = append(manifests, templates.KubernetesRedisTemplate)
+	}
+	if opts.IncludeEgress {
+		manifests = append(manifests, templates.KubernetesEgressTemplate)
+	}
+	if opts.IncludeIngress {
+		manifests = append(manifests, templates.KubernetesIngressTemplate)
+	}
+	for _, m := range manifests {
+		tmpl, err := template.New("kubernetes").Parse(m)
+		if err != nil {
+			return err
+		}
+		if err = tmpl.Execute(&buf, &content); err != nil {
+			return err
+		}
+	}
+
+	opts.Files.Manifest = path.Join(baseDir, "kubernetes.yaml")
+	return writeSecretFile(opts.Files.Manifest, buf.Bytes())
+}
+
+// liveKitKeys formats keys like the keys section of livekit.yaml, which is how LiveKit reads LIVEKIT_KEYS
+func liveKitKeys(keys map[string]string) string {
+	lines := make([]string, 0, len(keys))
+	for _, k := range sortedKeys(keys) {
+		lines = append(lines, k+": "+keys[k])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func marshalAndPrefix(v interface{}, prefix string) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return prefixLines(string(data), prefix), nil
+}
+
+func printKubernetesInstructions(opts *ServerOptions, conf *config.Config) {
+	fmt.Println("Please update DNS for the following domains to the load balancer of your ingress controller.")
+	fmt.Println(" *", opts.Domain)
+	if opts.IncludeIngress && opts.WHIPDomain != "" {
+		fmt.Println(" *", opts.WHIPDomain)
+	}
+	fmt.Println("And the following domain to the node running LiveKit, which uses host networking.")
+	fmt.Println(" *", opts.TURNDomain)
+	if opts.DNSProvider == "" {
+		fmt.Printf("cert-manager verifies %s with an HTTP-01 challenge answered by the ingress controller,\n", opts.TURNDomain)
+		fmt.Println("forward port 80 of that node to your ingress controller, or generate with --dns-provider to use DNS-01 instead.")
+	}
+	fmt.Println()
+	fmt.Println("The manifest requires cert-manager and an ingress controller to be installed in the cluster.")
+	fmt.Println("cert-manager will automatically acquire TLS certificates for the domains from Let's Encrypt.")
+	fmt.Printf("Deploy with: \"kubectl apply -f %s\"\n", path.Join(opts.Domain, "kubernetes.yaml"))
+	fmt.Println()
+
+	if opts.IncludeEgress || opts.IncludeIngress {
+		fmt.Println("Since you've enabled Egress/Ingress, we recommend running them on nodes with at least 4 cores")
+		fmt.Println()
+	}
+
+	fmt.Println("Please ensure the following ports are accessible on the nodes")
+	fmt.Printf(" * %d - for TURN/TLS\n", conf.TURN.TLSPort)
+	fmt.Printf(" * %d - for WebRTC over TCP\n", conf.RTC.TCPPort)
+	fmt.Printf(" * %d/UDP - for TURN/UDP\n", conf.TURN.UDPPort)
+	fmt.Printf(" * %d-%d/UDP - for WebRTC over UDP\n", conf.RTC.ICEPortRangeStart, conf.RTC.ICEPortRangeEnd)
+	if opts.IncludeIngress {
+		fmt.Printf(" * %d - for RTMP Ingress\n", DefaultRTMPPort)
+		fmt.Printf(" * %d/UDP - for WHIP Ingress WebRTC\n", DefaultRTCUDPPort)
+	}
+}
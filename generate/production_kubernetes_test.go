@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+type kubernetesResource struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	StringData map[string]string `yaml:"stringData"`
+	Spec       yaml.Node         `yaml:"spec"`
+}
+
+func readKubernetesManifest(t *testing.T, file string) map[string]*kubernetesResource {
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	resources := make(map[string]*kubernetesResource)
+	decoder := yaml.NewDecoder(f)
+	for {
+		r := &kubernetesResource{}
+		err = decoder.Decode(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		resources[r.Kind+"/"+r.Metadata.Name] = r
+	}
+	return resources
+}
+
+func TestGenerateKubernetes(t *testing.T) {
+	opts := testServerOptions()
+	opts.Target = TargetKubernetes
+	opts.DNSProvider = "cloudflare"
+	opts.DNSCredentials = map[string]string{"api_token": "cloudflare-token"}
+	require.NoError(t, opts.Validate())
+	conf, err := renderFiles(opts, t.TempDir())
+	require.NoError(t, err)
+
+	resources := readKubernetesManifest(t, opts.Files.Manifest)
+	for _, name := range []string{
+		"Namespace/livekit",
+		"Secret/livekit-keys",
+		"Secret/livekit-config",
+		"Secret/livekit-dns-credentials",
+		"ClusterIssuer/livekit-letsencrypt",
+		"Certificate/livekit-turn",
+		"Deployment/livekit",
+		"Secret/redis-config",
+		"StatefulSet/redis",
+		"Secret/egress-config",
+		"Secret/ingress-config",
+	} {
+		require.Contains(t, resources, name)
+	}
+	// configs hold the Redis password
+	for name, r := range resources {
+		require.NotEqual(t, "ConfigMap", r.Kind, name)
+	}
+	require.Equal(t, "cert-manager", resources["Secret/livekit-dns-credentials"].Metadata.Namespace)
+	require.Equal(t, "cloudflare-token", resources["Secret/livekit-dns-credentials"].StringData["api_token"])
+
+	// API keys are only provided through livekit-keys
+	lkConf := &config.Config{}
+	require.NoError(t, yaml.Unmarshal([]byte(resources["Secret/livekit-config"].StringData["livekit.yaml"]), lkConf))
+	require.Empty(t, lkConf.Keys)
+	require.Equal(t, conf.Redis.Password, lkConf.Redis.Password)
+	apiKey, apiSecret, err := getAPIKeySecret(conf, opts.APIKey)
+	require.NoError(t, err)
+	require.Equal(t, apiKey, resources["Secret/livekit-keys"].StringData["LIVEKIT_API_KEY"])
+	require.Equal(t, apiSecret, resources["Secret/livekit-keys"].StringData["LIVEKIT_API_SECRET"])
+	require.NotContains(t, resources["Secret/egress-config"].StringData["egress.yaml"], apiSecret)
+
+	issuer := struct {
+		ACME struct {
+			Solvers []struct {
+				Selector struct {
+					DNSNames []string `yaml:"dnsNames"`
+				} `yaml:"selector"`
+				DNS01 map[string]interface{} `yaml:"dns01"`
+			} `yaml:"solvers"`
+		} `yaml:"acme"`
+	}{}
+	require.NoError(t, resources["ClusterIssuer/livekit-letsencrypt"].Spec.Decode(&issuer))
+	require.Len(t, issuer.ACME.Solvers, 2)
+	require.Equal(t, []string{opts.TURNDomain}, issuer.ACME.Solvers[1].Selector.DNSNames)
+	require.Contains(t, issuer.ACME.Solvers[1].DNS01, "cloudflare")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/manifoldco/promptui"
+
+	"github.com/livekit/deploy/generate/templates"
+)
+
+// renderRedisConf renders the config of the bundled Redis
+func renderRedisConf(opts *ServerOptions) (string, error) {
+	tmpl, err := template.New("redis").Parse(templates.RedisConfTemplate)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.Buffer{}
+	if err = tmpl.Execute(&buf, opts); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// promptRedis asks for the connection to an external Redis
+func promptRedis(opts *ServerOptions) error {
+	r := &opts.Redis
+	modePrompt := promptui.Select{
+		Label: "Redis deployment",
+		Items: []string{
+			"Single Redis server",
+			"Redis Sentinel",
+		},
+		Stdout: BellSkipper,
+	}
+	idx, _, err := modePrompt.Run()
+	if err != nil {
+		return err
+	}
+
+	if idx == 0 {
+		if r.Address, err = runPrompt("Redis address (host:port)", "", validateAddress); err != nil {
+			return err
+		}
+	} else {
+		if r.SentinelMasterName, err = runPrompt("Sentinel master name", "mymaster", nil); err != nil {
+			return err
+		}
+		addresses, err := runPrompt("Sentinel addresses (host:port, comma separated)", "", func(s string) error {
+			for _, addr := range splitList(s) {
+				if err := validateAddress(addr); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		r.SentinelAddresses = splitList(addresses)
+		if r.SentinelPassword, err = runPrompt("Sentinel password (optional)", "", nil); err != nil {
+			return err
+		}
+	}
+
+	if r.Username, err = runPrompt("Redis username (optional)", "", nil); err != nil {
+		return err
+	}
+	if r.Password, err = runPrompt("Redis password (optional)", "", nil); err != nil {
+		return err
+	}
+	db, err := runPrompt("Redis DB index", "0", func(s string) error {
+		if n, err := strconv.Atoi(s); err != nil || n < 0 {
+			return errors.New("requires a DB index, i.e. 0")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.DB, _ = strconv.Atoi(db)
+
+	tlsPrompt := promptui.Select{
+		Label:  "Connect to Redis with TLS",
+		Items:  []string{"no", "yes"},
+		Stdout: BellSkipper,
+	}
+	if idx, _, err = tlsPrompt.Run(); err != nil {
+		return err
+	}
+	r.UseTLS = idx == 1
+	return nil
+}
+
+func runPrompt(label, defaultValue string, validate promptui.ValidateFunc) (string, error) {
+	prompt := promptui.Prompt{
+		Label:    label,
+		Default:  defaultValue,
+		Validate: validate,
+		Stdout:   BellSkipper,
+	}
+	value, err := prompt.Run()
+	if err == promptui.ErrAbort {
+		return "", nil
+	}
+	return strings.TrimSpace(value), err
+}
+
+func validateAddress(s string) error {
+	if _, port, err := net.SplitHostPort(s); err != nil || port == "" {
+		return errors.New("requires an address as host:port")
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/exp/slices"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	flagRetire = "retire"
+	flagLabel  = "label"
+)
+
+var rotateKeysFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  flagRetire,
+		Usage: "remove an API key replaced by a previous rotation, instead of adding a new one",
+	},
+	&cli.StringFlag{
+		Name:  flagLabel,
+		Usage: "label of the key to replace, instead of the one used by Egress and Ingress",
+	},
+	&cli.BoolFlag{
+		Name:  flagDryRun,
+		Usage: "print a diff of the files that would be generated, without writing them",
+	},
+}
+
+// rotateKeys adds a new API key to a deployment, replacing the key of Egress and Ingress or the one of a label.
+// The previous keys stay valid until they are retired, so clients and backends can move to the new key without downtime.
+func rotateKeys(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("usage: generate rotate-keys [--label <label> | --retire <key>] <dir>")
+	}
+	dir := c.Args().First()
+	baseDir := outputPath(dir)
+
+	opts, err := loadDeployment(c, baseDir)
+	if err != nil {
+		return err
+	}
+	if err = resolveServerOptions(c, opts, false); err != nil {
+		return err
+	}
+	current, _, err := getAPIKeySecret(&config.Config{Keys: opts.Keys}, opts.APIKey)
+	if err != nil {
+		return err
+	}
+	// a deployment with a single key doesn't record which one Egress and Ingress use
+	opts.APIKey = current
+	label := c.String(flagLabel)
+	if label != "" {
+		i := slices.IndexFunc(opts.LabeledKeys, func(k LabeledKey) bool { return k.Label == label })
+		if i < 0 {
+			return fmt.Errorf("no key labeled %s in %s", label, dir)
+		}
+		current = opts.LabeledKeys[i].Key
+	} else {
+		label = opts.KeyLabel(current)
+	}
+
+	keys := make(map[string]string, len(opts.Keys)+1)
+	for k, s := range opts.Keys {
+		keys[k] = s
+	}
+	retire := c.String(flagRetire)
+	if retire != "" {
+		if _, ok := keys[retire]; !ok {
+			return fmt.Errorf("api key %s not found in %s", retire, dir)
+		}
+		if retire == opts.APIKey || opts.KeyLabel(retire) != "" {
+			return fmt.Errorf("api key %s is in use, rotate to a new key before retiring it", retire)
+		}
+		delete(keys, retire)
+	} else {
+		for k, s := range newAPIKeys() {
+			keys[k] = s
+			if current == opts.APIKey {
+				opts.APIKey = k
+			}
+			for i := range opts.LabeledKeys {
+				if opts.LabeledKeys[i].Key == current {
+					opts.LabeledKeys[i].Key = k
+				}
+			}
+			current = k
+		}
+	}
+	opts.Keys = keys
+
+	conf, err := rewriteDeployment(c, opts, baseDir)
+	if err != nil || conf == nil {
+		return err
+	}
+
+	switch {
+	case retire != "":
+		fmt.Printf("Retired API key %s, tokens signed with it are no longer accepted once the deployment is updated.\n", retire)
+	case current == opts.APIKey:
+		fmt.Printf("Added API key %s, Egress and Ingress use it from now on.\n", current)
+	default:
+		fmt.Printf("Added API key %s, replacing the previous key labeled %s.\n", current, label)
+	}
+	if retire == "" {
+		fmt.Println()
+		fmt.Println("API Key: " + current)
+		fmt.Println("API Secret: " + keys[current])
+	}
+	fmt.Println()
+	fmt.Println("Copy the updated files in", dir, "to the server and restart LiveKit, Egress and Ingress to load the keys.")
+
+	var previous []string
+	for _, k := range sortedKeys(keys) {
+		if k != opts.APIKey && opts.KeyLabel(k) == "" {
+			previous = append(previous, k)
+		}
+	}
+	if len(previous) > 0 {
+		fmt.Printf("Previous keys stay valid: %s\n", strings.Join(previous, ", "))
+		fmt.Println("Once clients and backends use the new key, retire each of them with:")
+		for _, k := range previous {
+			fmt.Printf("generate rotate-keys --retire %s %s\n", k, dir)
+		}
+	}
+	return nil
+}
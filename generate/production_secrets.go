@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// envFile holds the API keys of a compose deployment with SecretsEnv, docker-compose passes them
+// to LiveKit, Egress and Ingress like the livekit-keys Secret of the kubernetes target
+const envFile = ".env"
+
+func generateEnvFile(opts *ServerOptions, conf *config.Config, baseDir string) error {
+	if !opts.SecretsEnv {
+		return nil
+	}
+	apiKey, apiSecret, err := getAPIKeySecret(conf, opts.APIKey)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("# API keys of LiveKit, and the one Egress and Ingress use, docker-compose.yaml passes them to the services\n")
+	fmt.Fprintf(buf, "LIVEKIT_KEYS=%s\n", envKeys(conf.Keys))
+	fmt.Fprintf(buf, "LIVEKIT_API_KEY=%s\n", apiKey)
+	fmt.Fprintf(buf, "LIVEKIT_API_SECRET=%s\n", apiSecret)
+
+	opts.Files.Env = path.Join(baseDir, envFile)
+	return writeSecretFile(opts.Files.Env, buf.Bytes())
+}
+
+// envKeys formats keys as a YAML flow mapping, LiveKit parses LIVEKIT_KEYS as YAML and .env values are single lines
+func envKeys(keys map[string]string) string {
+	pairs := make([]string, 0, len(keys))
+	for _, k := range sortedKeys(keys) {
+		pairs = append(pairs, k+": "+keys[k])
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// readEnvKeys reads the API keys from the .env file of a deployment
+func readEnvKeys(dir string) (map[string]string, error) {
+	f, err := os.Open(path.Join(dir, envFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "LIVEKIT_KEYS=") {
+			continue
+		}
+		keys := make(map[string]string)
+		if err = yaml.Unmarshal([]byte(strings.TrimPrefix(line, "LIVEKIT_KEYS=")), &keys); err != nil {
+			return nil, fmt.Errorf("could not parse LIVEKIT_KEYS in %s: %w", envFile, err)
+		}
+		return keys, nil
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no LIVEKIT_KEYS found in %s", envFile)
+}
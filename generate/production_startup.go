@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
-	"os"
+	"fmt"
 	"path"
+	"strings"
 	"text/template"
 
 	"github.com/livekit/deploy/generate/templates"
+	"github.com/livekit/livekit-server/pkg/config"
 )
 
 type cloudInitContent struct {
@@ -18,19 +20,114 @@ type cloudInitContent struct {
 	RedisConf           string
 	EgressConf          string
 	IngressConf         string
+	EnvFile             string
 	UpdateIPScript      string
+	DockerCompose       string
+	FirewallPorts       []string
+	FirewallScript      string
+	Certificates        []cloudInitFile
+	// only used by the systemd and podman targets, which write the units to their own directory
+	ServerVersion    string
+	CaddyModules     []string
+	GeneratorRelease string
+	Units            []cloudInitFile
+	// configs the compose service gives to the users of their containers before starting them
+	ContainerConfigs []containerConfig
 }
 
-func generateStartupScript(opts *ServerOptions, baseDir string) error {
+// cloudInitFile is a file written to a path relative to InstallPrefix
+type cloudInitFile struct {
+	Path    string
+	Content string
+}
+
+// ServiceName is the systemd service of a unit file, quadlet names the service after the container file
+func (f cloudInitFile) ServiceName() string {
+	return strings.TrimSuffix(f.Path, path.Ext(f.Path)) + ".service"
+}
+
+// containerConfig is a config mounted into a container whose image reads it as an unprivileged user
+type containerConfig struct {
+	Path  string
+	Image string
+	// the user the image switches to, empty for the one it runs as
+	User string
+}
+
+// containerConfigs are the configs of the docker-compose file read by unprivileged users, matching its images
+func containerConfigs(opts *ServerOptions) []containerConfig {
+	var configs []containerConfig
+	if opts.LocalRedis {
+		configs = append(configs, containerConfig{Path: "redis.conf", Image: "redis:7-alpine", User: "redis"})
+	}
+	if opts.IncludeEgress {
+		configs = append(configs, containerConfig{Path: "egress.yaml", Image: "livekit/egress:latest"})
+	}
+	if opts.IncludeIngress {
+		configs = append(configs, containerConfig{Path: "ingress.yaml", Image: "livekit/ingress:latest"})
+	}
+	return configs
+}
+
+// ChownCommand gives the config to the user of the container, looked up in its image, so it stays private to them
+func (c containerConfig) ChownCommand() string {
+	return fmt.Sprintf(`chown "$(docker run --rm --entrypoint id %s %s)" %s`,
+		c.Image, strings.TrimSpace("-u "+c.User), path.Join(installPrefix, c.Path))
+}
+
+// ExecStartPre is ChownCommand for a unit, systemd expands $$ to $
+func (c containerConfig) ExecStartPre() string {
+	return fmt.Sprintf("/bin/sh -c '%s'", strings.ReplaceAll(c.ChownCommand(), "$", "$$"))
+}
+
+// generateCommand runs the generator on the server, from its image, or its release binary without a container runtime
+func generateCommand(target DeploymentTarget) string {
+	switch target {
+	case TargetSystemd:
+		return "/usr/local/bin/generate"
+	case TargetPodman:
+		return fmt.Sprintf("podman run --rm --network host -v %s:%s:z docker.io/livekit/generate", installPrefix, installPrefix)
+	default:
+		return fmt.Sprintf("docker run --rm --network host -v %s:%s livekit/generate", installPrefix, installPrefix)
+	}
+}
+
+// updateIPScript is run at startup, before Caddy, to point its TURN upstream to the local IP
+func updateIPScript(target DeploymentTarget) (string, error) {
+	tmpl, err := template.New("update-ip").Parse(templates.UpdateIPScriptTemplate)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, &struct {
+		InstallPrefix string
+		Generate      string
+	}{InstallPrefix: installPrefix, Generate: generateCommand(target)})
+	return buf.String(), err
+}
+
+func generateStartupScript(opts *ServerOptions, conf *config.Config, baseDir string) error {
 	if opts.CloudInit == StartupScriptNone {
 		return nil
 	}
+	if opts.CloudInit == StartupScriptIgnition {
+		return generateIgnition(opts, baseDir)
+	}
 
 	// prep files
 	var err error
 	content := cloudInitContent{
-		InstallPrefix:  "/opt/livekit",
-		UpdateIPScript: templates.UpdateIPScript,
+		InstallPrefix:    installPrefix,
+		DockerCompose:    "/usr/local/bin/docker-compose",
+		ServerVersion:    opts.ServerVersion,
+		CaddyModules:     opts.CaddyModules(),
+		ContainerConfigs: containerConfigs(opts),
+	}
+	if opts.CloudInit.UsesComposePlugin() {
+		content.DockerCompose = "/usr/bin/docker compose"
+	}
+	for _, p := range hostPorts(opts, conf) {
+		content.FirewallPorts = append(content.FirewallPorts, p.Firewall())
 	}
 	// six space indent for yaml types
 	indent := "      "
@@ -40,11 +137,20 @@ func generateStartupScript(opts *ServerOptions, baseDir string) error {
 	if content.LiveKitConfig, err = readAndPrefix(opts.Files.LiveKit, indent); err != nil {
 		return err
 	}
-	if content.CaddyConfig, err = readAndPrefix(opts.Files.Caddy, indent); err != nil {
-		return err
+	if opts.UsesCaddy() {
+		if content.CaddyConfig, err = readAndPrefix(opts.Files.Caddy, indent); err != nil {
+			return err
+		}
+		if opts.Target == TargetSystemd {
+			if content.GeneratorRelease, err = generatorRelease(); err != nil {
+				return fmt.Errorf("could not get the generator release: %w", err)
+			}
+		}
 	}
-	if content.DockerComposeConfig, err = readAndPrefix(opts.Files.Docker, indent); err != nil {
-		return err
+	if opts.Files.Docker != "" {
+		if content.DockerComposeConfig, err = readAndPrefix(opts.Files.Docker, indent); err != nil {
+			return err
+		}
 	}
 	if opts.LocalRedis {
 		if content.RedisConf, err = readAndPrefix(opts.Files.RedisConf, indent); err != nil {
@@ -61,7 +167,41 @@ func generateStartupScript(opts *ServerOptions, baseDir string) error {
 			return err
 		}
 	}
-	content.UpdateIPScript = prefixLines(templates.UpdateIPScript, indent)
+	if opts.Files.Env != "" {
+		if content.EnvFile, err = readAndPrefix(opts.Files.Env, indent); err != nil {
+			return err
+		}
+	}
+	if opts.Files.Firewall != "" {
+		if content.FirewallScript, err = readAndPrefix(opts.Files.Firewall, indent); err != nil {
+			return err
+		}
+	}
+	for _, file := range opts.Files.Certificates {
+		f := cloudInitFile{
+			Path: path.Join(certsDir, path.Base(file)),
+		}
+		if f.Content, err = readAndPrefix(file, indent); err != nil {
+			return err
+		}
+		content.Certificates = append(content.Certificates, f)
+	}
+	for _, file := range opts.Files.Units {
+		f := cloudInitFile{
+			Path: path.Base(file),
+		}
+		if f.Content, err = readAndPrefix(file, indent); err != nil {
+			return err
+		}
+		content.Units = append(content.Units, f)
+	}
+	if opts.UsesCaddy() {
+		updateIP, err := updateIPScript(opts.Target)
+		if err != nil {
+			return err
+		}
+		content.UpdateIPScript = prefixLines(updateIP, indent)
+	}
 
 	// system service
 	tmpl, err := template.New("systemd").Parse(templates.SystemdServiceTemplate)
@@ -74,13 +214,21 @@ func generateStartupScript(opts *ServerOptions, baseDir string) error {
 	}
 	content.SystemService = prefixLines(buf.String(), indent)
 
-	tmpl, err = template.New("cloud-init").Parse(opts.CloudInit.Template())
+	startupTemplate := opts.CloudInit.Template()
+	switch opts.Target {
+	case TargetSystemd:
+		startupTemplate = templates.SystemdStartupScriptTemplate
+	case TargetPodman:
+		startupTemplate = templates.PodmanStartupScriptTemplate
+	}
+	tmpl, err = template.New("cloud-init").Parse(startupTemplate)
 	if err != nil {
 		return err
 	}
 
+	// the startup script embeds all configs
 	target := path.Join(baseDir, string(opts.CloudInit))
-	f, err := os.Create(target)
+	f, err := createSecretFile(target)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type cloudInitConfig struct {
+	Packages   []string `yaml:"packages"`
+	WriteFiles []struct {
+		Path        string `yaml:"path"`
+		Permissions string `yaml:"permissions"`
+		Content     string `yaml:"content"`
+	} `yaml:"write_files"`
+	RunCmd []interface{} `yaml:"runcmd"`
+}
+
+func TestGenerateCloudInit(t *testing.T) {
+	testCases := []struct {
+		kind     StartupScriptKind
+		firewall FirewallKind
+	}{
+		{StartupScriptCloudInitDebian, FirewallUFW},
+		{StartupScriptCloudInitRHEL, FirewallFirewalld},
+		{StartupScriptCloudInitAL2023, FirewallNftables},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.kind), func(t *testing.T) {
+			dir := t.TempDir()
+			opts := testServerOptions()
+			opts.CloudInit = tc.kind
+			opts.Firewall = tc.firewall
+			require.NoError(t, opts.Validate())
+			_, err := renderFiles(opts, dir)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(path.Join(dir, string(tc.kind)))
+			require.NoError(t, err)
+			cloudInit := &cloudInitConfig{}
+			require.NoError(t, yaml.Unmarshal(data, cloudInit))
+			require.NotEmpty(t, cloudInit.RunCmd)
+
+			// every generated file is embedded as is, with the secrets only readable by root
+			files := make(map[string]string)
+			for _, f := range cloudInit.WriteFiles {
+				files[f.Path] = f.Permissions
+			}
+			require.Equal(t, map[string]string{
+				"/opt/livekit/livekit.yaml":                  "0600",
+				"/opt/livekit/caddy.yaml":                    "0600",
+				"/opt/livekit/update_ip.sh":                  "",
+				"/opt/livekit/docker-compose.yaml":           "",
+				"/etc/systemd/system/livekit-docker.service": "",
+				"/opt/livekit/redis.conf":                    "0600",
+				"/opt/livekit/egress.yaml":                   "0600",
+				"/opt/livekit/ingress.yaml":                  "0600",
+				"/opt/livekit/firewall.sh":                   "0755",
+			}, files)
+			for _, f := range cloudInit.WriteFiles {
+				var src string
+				switch path.Base(f.Path) {
+				case "livekit.yaml":
+					src = opts.Files.LiveKit
+				case "caddy.yaml":
+					src = opts.Files.Caddy
+				case "docker-compose.yaml":
+					src = opts.Files.Docker
+				case "redis.conf":
+					src = opts.Files.RedisConf
+				case "egress.yaml":
+					src = opts.Files.Egress
+				case "ingress.yaml":
+					src = opts.Files.Ingress
+				case "firewall.sh":
+					src = opts.Files.Firewall
+				default:
+					continue
+				}
+				expected, err := os.ReadFile(src)
+				require.NoError(t, err)
+				require.Equal(t, string(expected), f.Content, f.Path)
+			}
+		})
+	}
+}
+
+func TestContainerConfigsArePrivate(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.CloudInit = StartupScriptShellScript
+	require.NoError(t, opts.Validate())
+	_, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+
+	for _, file := range []string{opts.Files.RedisConf, opts.Files.Egress, opts.Files.Ingress} {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(secretFilePerms), info.Mode().Perm(), file)
+	}
+
+	// the service gives the configs to the users the images run as before starting the containers
+	data, err := os.ReadFile(path.Join(dir, string(StartupScriptShellScript)))
+	require.NoError(t, err)
+	script := string(data)
+	for _, s := range []string{
+		`ExecStartPre=/bin/sh -c 'chown "$$(docker run --rm --entrypoint id redis:7-alpine -u redis)" /opt/livekit/redis.conf'`,
+		`ExecStartPre=/bin/sh -c 'chown "$$(docker run --rm --entrypoint id livekit/egress:latest -u)" /opt/livekit/egress.yaml'`,
+		`ExecStartPre=/bin/sh -c 'chown "$$(docker run --rm --entrypoint id livekit/ingress:latest -u)" /opt/livekit/ingress.yaml'`,
+		"chmod 600 /opt/livekit/redis.conf\n",
+		"chmod 600 /opt/livekit/egress.yaml\n",
+		"chmod 600 /opt/livekit/ingress.yaml\n",
+	} {
+		require.Contains(t, script, s)
+	}
+}
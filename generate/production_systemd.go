@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path"
+	"text/template"
+
+	"github.com/livekit/deploy/generate/templates"
+)
+
+const (
+	// systemdDir holds the units of the systemd target
+	systemdDir = "systemd"
+	// quadletDir holds the Quadlet units of the podman target
+	quadletDir = "quadlet"
+)
+
+// unitTemplate is a unit written for a service, when it's part of the deployment
+type unitTemplate struct {
+	name     string
+	template string
+	enabled  bool
+}
+
+type unitContent struct {
+	*ServerOptions
+	InstallPrefix string
+}
+
+// generateSystemd writes a unit for each service, running the binaries installed by the startup script
+func generateSystemd(opts *ServerOptions, baseDir string) error {
+	return generateUnits(opts, path.Join(baseDir, systemdDir), ".service", []unitTemplate{
+		{"livekit", templates.SystemdLiveKitTemplate, true},
+		{"livekit-caddy", templates.SystemdCaddyTemplate, opts.UsesCaddy()},
+		{"livekit-redis", templates.SystemdRedisTemplate, opts.LocalRedis},
+		{"livekit-egress", templates.SystemdEgressTemplate, opts.IncludeEgress},
+		{"livekit-ingress", templates.SystemdIngressTemplate, opts.IncludeIngress},
+	})
+}
+
+// generatePodman writes a Quadlet unit for each container of the docker-compose file
+func generatePodman(opts *ServerOptions, baseDir string) error {
+	return generateUnits(opts, path.Join(baseDir, quadletDir), ".container", []unitTemplate{
+		{"livekit", templates.PodmanLiveKitTemplate, true},
+		{"livekit-caddy", templates.PodmanCaddyTemplate, opts.UsesCaddy()},
+		{"livekit-redis", templates.PodmanRedisTemplate, opts.LocalRedis},
+		{"livekit-egress", templates.PodmanEgressTemplate, opts.IncludeEgress},
+		{"livekit-ingress", templates.PodmanIngressTemplate, opts.IncludeIngress},
+	})
+}
+
+func generateUnits(opts *ServerOptions, dir, ext string, units []unitTemplate) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	content := &unitContent{
+		ServerOptions: opts,
+		InstallPrefix: installPrefix,
+	}
+
+	opts.Files.Units = nil
+	for _, unit := range units {
+		if !unit.enabled {
+			continue
+		}
+		tmpl, err := template.New(unit.name).Parse(unit.template)
+		if err != nil {
+			return err
+		}
+		file := path.Join(dir, unit.name+ext)
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		err = tmpl.Execute(f, content)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		opts.Files.Units = append(opts.Files.Units, file)
+	}
+	return nil
+}
+
+// unitFiles lists the unit files of a service for every target that has them
+func unitFiles(name string) []string {
+	return []string{
+		path.Join(systemdDir, name+".service"),
+		path.Join(quadletDir, name+".container"),
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readUnit parses a systemd unit into the values of each section's keys
+func readUnit(t *testing.T, file string) map[string]map[string][]string {
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	unit := make(map[string]map[string][]string)
+	var section string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.Trim(line, "[]")
+			unit[section] = make(map[string][]string)
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		require.True(t, ok, line)
+		require.NotEmpty(t, section, line)
+		unit[section][key] = append(unit[section][key], value)
+	}
+	require.NoError(t, scanner.Err())
+	return unit
+}
+
+func TestGeneratePodman(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.Target = TargetPodman
+	require.NoError(t, opts.Validate())
+	_, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+	require.NoFileExists(t, path.Join(dir, "docker-compose.yaml"))
+
+	var names []string
+	for _, file := range opts.Files.Units {
+		names = append(names, path.Base(file))
+	}
+	require.ElementsMatch(t, []string{
+		"livekit.container",
+		"livekit-caddy.container",
+		"livekit-redis.container",
+		"livekit-egress.container",
+		"livekit-ingress.container",
+	}, names)
+
+	for _, file := range opts.Files.Units {
+		unit := readUnit(t, file)
+		container := unit["Container"]
+		require.NotNil(t, container, file)
+		require.Len(t, container["Image"], 1, file)
+		require.True(t, strings.HasPrefix(container["Image"][0], "docker.io/"), file)
+		require.Equal(t, []string{"host"}, container["Network"], file)
+		require.Equal(t, []string{"always"}, unit["Service"]["Restart"], file)
+		require.NotEmpty(t, unit["Install"]["WantedBy"], file)
+
+		// the volumes mount the generated files from the install directory
+		for _, volume := range container["Volume"] {
+			src := strings.Split(volume, ":")[0]
+			require.True(t, strings.HasPrefix(src, installPrefix+"/"), volume)
+			if path.Base(src) != "caddy_data" {
+				require.FileExists(t, path.Join(dir, strings.TrimPrefix(src, installPrefix)), volume)
+			}
+		}
+	}
+
+	unit := readUnit(t, path.Join(dir, quadletDir, "livekit.container"))
+	require.Equal(t, []string{"docker.io/livekit/livekit-server:" + opts.ServerVersion}, unit["Container"]["Image"])
+	require.Equal(t, []string{"livekit-redis.service"}, unit["Unit"]["Requires"])
+
+	// the configs holding secrets are given to the users the images run as
+	for name, config := range map[string]string{
+		"livekit-redis.container":   "redis.conf",
+		"livekit-egress.container":  "egress.yaml",
+		"livekit-ingress.container": "ingress.yaml",
+	} {
+		unit = readUnit(t, path.Join(dir, quadletDir, name))
+		require.Len(t, unit["Service"]["ExecStartPre"], 1, name)
+		pre := unit["Service"]["ExecStartPre"][0]
+		require.Contains(t, pre, "podman run --rm --entrypoint id "+unit["Container"]["Image"][0]+" -u", name)
+		require.Contains(t, pre, "chown $$uid "+path.Join(installPrefix, config)+";", name)
+	}
+}
+
+func TestGenerateSystemd(t *testing.T) {
+	// a release build pins its own release, without looking up the latest one
+	defer func(v string) { version = v }(version)
+	version = "v1.2.3"
+
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.Target = TargetSystemd
+	opts.CloudInit = StartupScriptShellScript
+	require.NoError(t, opts.Validate())
+	_, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+	require.NoFileExists(t, path.Join(dir, "docker-compose.yaml"))
+
+	execStart := make(map[string]string)
+	for _, file := range opts.Files.Units {
+		unit := readUnit(t, file)
+		require.Equal(t, []string{"livekit"}, unit["Service"]["User"], file)
+		require.Equal(t, []string{"always"}, unit["Service"]["Restart"], file)
+		require.Equal(t, []string{"multi-user.target"}, unit["Install"]["WantedBy"], file)
+		require.Len(t, unit["Service"]["ExecStart"], 1, file)
+		execStart[path.Base(file)] = unit["Service"]["ExecStart"][0]
+	}
+	require.Equal(t, map[string]string{
+		"livekit.service":         "/usr/local/bin/livekit-server --config /opt/livekit/livekit.yaml",
+		"livekit-caddy.service":   "/usr/local/bin/caddy run --config /opt/livekit/caddy.yaml --adapter yaml",
+		"livekit-redis.service":   "/usr/local/bin/redis-server /opt/livekit/redis.conf",
+		"livekit-egress.service":  "/usr/local/bin/egress",
+		"livekit-ingress.service": "/usr/local/bin/ingress",
+	}, execStart)
+
+	egress := readUnit(t, path.Join(dir, systemdDir, "livekit-egress.service"))
+	require.Contains(t, egress["Service"]["Environment"], "EGRESS_CONFIG_FILE=/opt/livekit/egress.yaml")
+	require.Len(t, egress["Service"]["ExecStartPre"], 1)
+	require.True(t, strings.HasPrefix(egress["Service"]["ExecStartPre"][0], "/usr/bin/pulseaudio "))
+	ingress := readUnit(t, path.Join(dir, systemdDir, "livekit-ingress.service"))
+	require.Contains(t, ingress["Service"]["Environment"], "INGRESS_CONFIG_FILE=/opt/livekit/ingress.yaml")
+
+	// the startup script installs the binaries of the units, and writes the configs and units
+	data, err := os.ReadFile(path.Join(dir, string(StartupScriptShellScript)))
+	require.NoError(t, err)
+	script := string(data)
+	for _, s := range []string{
+		"/releases/download/${VERSION}/livekit_${VERSION#v}_linux_${ARCH}.tar.gz",
+		"xcaddy@latest build \\\n  --output /usr/local/bin/caddy --with github.com/mholt/caddy-l4 --with github.com/abiosoft/caddy-yaml\n",
+		"/releases/download/v1.2.3/generate_linux_${ARCH}",
+		"${GENERATOR_URL}.sha256",
+		"apt-get install -y redis-server",
+		"libgstreamer1.0-dev",
+		"| sha256sum -c -",
+		"install -y google-chrome-stable",
+		"CGO_ENABLED=1 go build -o /usr/local/bin/egress ./cmd/server",
+		"CGO_ENABLED=1 go build -o /usr/local/bin/ingress ./cmd/server",
+	} {
+		require.Contains(t, script, s)
+	}
+	for _, f := range []string{"livekit.yaml", "caddy.yaml", "redis.conf", "egress.yaml", "ingress.yaml"} {
+		require.Contains(t, script, "cat << EOF > /opt/livekit/"+f+"\n")
+	}
+	for _, file := range opts.Files.Units {
+		unit, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.Contains(t, script, "cat << EOF > /opt/livekit/systemd/"+path.Base(file)+"\n"+string(unit))
+	}
+	sh, err := exec.LookPath("sh")
+	if err == nil {
+		out, err := exec.Command(sh, "-n", path.Join(dir, string(StartupScriptShellScript))).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path"
+	"text/template"
+
+	"github.com/manifoldco/promptui"
+
+	"github.com/livekit/deploy/generate/templates"
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// terraformDir holds the Terraform configuration launching the deployment on AWS
+const terraformDir = "terraform"
+
+type terraformContent struct {
+	Name         string
+	Domain       string
+	Domains      []string
+	InstanceType string
+	AMIOwner     string
+	AMIName      string
+	UserData     string
+	Gzip         bool
+	DualStack    bool
+	Ports        []hostPort
+}
+
+// terraformAMI is the image matching the distribution a startup script is written for
+func terraformAMI(kind StartupScriptKind) (owner, name string) {
+	switch kind {
+	case StartupScriptCloudInitAmazon:
+		return "amazon", "amzn2-ami-hvm-*-x86_64-gp2"
+	case StartupScriptCloudInitAL2023:
+		return "amazon", "al2023-ami-2023.*-x86_64"
+	case StartupScriptCloudInitDebian:
+		return "136693071363", "debian-12-amd64-*"
+	case StartupScriptCloudInitRHEL:
+		return "792107900819", "Rocky-9-EC2-Base-9.*x86_64*"
+	case StartupScriptIgnition:
+		return "125523088429", "fedora-coreos-*-x86_64"
+	default:
+		// Ubuntu for the shell script as well
+		return "099720109477", "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*"
+	}
+}
+
+// generateTerraform writes a Terraform configuration launching an EC2 instance with the startup script as user data
+func generateTerraform(opts *ServerOptions, conf *config.Config, baseDir string) error {
+	dir := path.Join(baseDir, terraformDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	content := &terraformContent{
+		Name:         cloudResourceName(opts.Domain),
+		Domain:       opts.Domain,
+		Domains:      []string{opts.Domain, opts.TURNDomain},
+		InstanceType: "c6i.large",
+		UserData:     string(opts.CloudInit),
+		// Ignition doesn't read compressed user data
+		Gzip:      opts.CloudInit != StartupScriptIgnition,
+		Ports:     hostPorts(opts, conf),
+		DualStack: opts.DualStack,
+	}
+	if opts.IncludeIngress && opts.WHIPDomain != "" {
+		content.Domains = append(content.Domains, opts.WHIPDomain)
+	}
+	if opts.IncludeEgress || opts.IncludeIngress {
+		content.InstanceType = "c6i.xlarge"
+	}
+	content.AMIOwner, content.AMIName = terraformAMI(opts.CloudInit)
+
+	tmpl, err := template.New("terraform").Parse(templates.TerraformAWSTemplate)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path.Join(dir, "main.tf"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return tmpl.Execute(f, content)
+}
+
+func selectTerraform(opts *ServerOptions) error {
+	terraformPrompt := promptui.Select{
+		Label:  "Generate a Terraform configuration launching the server on AWS EC2?",
+		Items:  []string{"no", "yes"},
+		Stdout: BellSkipper,
+	}
+	idx, _, err := terraformPrompt.Run()
+	if err != nil {
+		return err
+	}
+	opts.Terraform = idx == 1
+	return nil
+}
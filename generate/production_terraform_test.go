@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	terraformIngressRegexp  = regexp.MustCompile(`(?m)^  ingress \{\n(?:    .*\n)*  \}`)
+	terraformUserDataRegexp = regexp.MustCompile(`(?m)^  (user_data(?:_base64)?) += (.*)$`)
+	terraformRecordRegexp   = regexp.MustCompile(`(?m)^  name    = "(.*)"\n  type    = "A"$`)
+)
+
+func TestGenerateTerraform(t *testing.T) {
+	testCases := []struct {
+		kind     StartupScriptKind
+		userData string
+	}{
+		{StartupScriptCloudInitDebian, `base64gzip(file("${path.module}/../cloud_init.debian.yaml"))`},
+		{StartupScriptIgnition, `file("${path.module}/../ignition.json")`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.kind), func(t *testing.T) {
+			dir := t.TempDir()
+			opts := testServerOptions()
+			opts.CloudInit = tc.kind
+			opts.Terraform = true
+			require.NoError(t, opts.Validate())
+			conf, err := renderFiles(opts, dir)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(path.Join(dir, terraformDir, "main.tf"))
+			require.NoError(t, err)
+			tf := string(data)
+			require.Equal(t, strings.Count(tf, "{"), strings.Count(tf, "}"))
+
+			// the user data is the startup script next to the terraform directory
+			m := terraformUserDataRegexp.FindAllStringSubmatch(tf, -1)
+			require.Len(t, m, 1)
+			require.Equal(t, tc.userData, m[0][2])
+			require.FileExists(t, path.Join(dir, string(tc.kind)))
+
+			owner, name := terraformAMI(tc.kind)
+			require.Contains(t, tf, fmt.Sprintf("owners      = [%q]", owner))
+			require.Contains(t, tf, fmt.Sprintf("values = [%q]", name))
+			require.Contains(t, tf, `default     = "c6i.xlarge"`)
+
+			ports := hostPorts(opts, conf)
+			ingress := terraformIngressRegexp.FindAllString(tf, -1)
+			require.Len(t, ingress, len(ports))
+			for i, p := range ports {
+				require.Contains(t, ingress[i], fmt.Sprintf("from_port        = %d\n", p.Port))
+				require.Contains(t, ingress[i], fmt.Sprintf("to_port          = %d\n", p.LastPort()))
+				require.Contains(t, ingress[i], fmt.Sprintf("protocol         = %q\n", p.Protocol))
+			}
+
+			var records []string
+			for _, r := range terraformRecordRegexp.FindAllStringSubmatch(tf, -1) {
+				records = append(records, r[1])
+			}
+			require.Equal(t, []string{opts.Domain, opts.TURNDomain, opts.WHIPDomain}, records)
+		})
+	}
+}
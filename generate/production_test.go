@@ -1,9 +1,15 @@
 package main
 
 import (
+	"os"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/redis"
 )
 
 func TestDomainValidation(t *testing.T) {
@@ -38,3 +44,265 @@ func TestDomainValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAPIKeySecret(t *testing.T) {
+	conf := &config.Config{Keys: map[string]string{"old": "old-secret"}}
+	key, secret, err := getAPIKeySecret(conf, "")
+	require.NoError(t, err)
+	require.Equal(t, "old", key)
+	require.Equal(t, "old-secret", secret)
+
+	// during a rotation, the key has to be selected
+	conf.Keys["new"] = "new-secret"
+	_, _, err = getAPIKeySecret(conf, "")
+	require.Error(t, err)
+	key, secret, err = getAPIKeySecret(conf, "new")
+	require.NoError(t, err)
+	require.Equal(t, "new", key)
+	require.Equal(t, "new-secret", secret)
+
+	_, _, err = getAPIKeySecret(conf, "missing")
+	require.Error(t, err)
+}
+
+func TestAssignAPIKeys(t *testing.T) {
+	opts := &ServerOptions{Keys: map[string]string{"existing": "secret"}}
+	opts.setKeyLabels([]string{"service", "product"})
+	assignAPIKeys(opts)
+
+	// the existing key stays with Egress and Ingress
+	require.Len(t, opts.Keys, 2)
+	require.Equal(t, "existing", opts.LabeledKeys[0].Key)
+	require.Equal(t, "existing", opts.APIKey)
+	require.Contains(t, opts.Keys, opts.LabeledKeys[1].Key)
+	require.Equal(t, "product", opts.KeyLabel(opts.LabeledKeys[1].Key))
+
+	// relabeling keeps the keys of the labels that remain
+	product := opts.LabeledKeys[1].Key
+	opts.setKeyLabels([]string{"product", "other"})
+	assignAPIKeys(opts)
+	require.Len(t, opts.Keys, 3)
+	require.Equal(t, product, opts.APIKey)
+	require.Empty(t, opts.KeyLabel("existing"))
+}
+
+func TestEnvFileKeys(t *testing.T) {
+	dir := t.TempDir()
+	keys := map[string]string{"APIkey1": "secret1", "APIkey2": "secret2"}
+	opts := &ServerOptions{SecretsEnv: true, APIKey: "APIkey2"}
+	require.NoError(t, generateEnvFile(opts, &config.Config{Keys: keys}, dir))
+
+	info, err := os.Stat(opts.Files.Env)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(secretFilePerms), info.Mode().Perm())
+
+	data, err := os.ReadFile(opts.Files.Env)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "LIVEKIT_API_KEY=APIkey2\nLIVEKIT_API_SECRET=secret2\n")
+
+	read, err := readEnvKeys(dir)
+	require.NoError(t, err)
+	require.Equal(t, keys, read)
+}
+
+// testServerOptions is a single server deployment with every component
+func testServerOptions() *ServerOptions {
+	opts := &ServerOptions{
+		IncludeEgress:  true,
+		IncludeIngress: true,
+		Domain:         "livekit.myhost.com",
+		TURNDomain:     "livekit-turn.myhost.com",
+		WHIPDomain:     "livekit-whip.myhost.com",
+		LocalRedis:     true,
+	}
+	opts.setDefaults()
+	return opts
+}
+
+func TestGenerateFilesKeepsDirOnError(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	_, err := generateFiles(opts, dir, nil)
+	require.NoError(t, err)
+	existing, err := os.ReadFile(path.Join(dir, "livekit.yaml"))
+	require.NoError(t, err)
+
+	// the certificate is only read after livekit.yaml was rendered
+	opts.SSLIssuer = SSLIssuerCustom
+	opts.Certificates = []CertificateFiles{{Domain: opts.Domain, CertFile: path.Join(dir, "missing.crt"), KeyFile: path.Join(dir, "missing.key")}}
+	opts.Redis.Password = "changed"
+	_, err = generateFiles(opts, dir, []string{"egress.yaml"})
+	require.Error(t, err)
+
+	data, err := os.ReadFile(path.Join(dir, "livekit.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, string(existing), string(data))
+	require.FileExists(t, path.Join(dir, "egress.yaml"))
+}
+
+func TestFromFileAfterRotation(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	_, err := generateFiles(opts, dir, nil)
+	require.NoError(t, err)
+
+	// rotate-keys adds a key and records it as api_key
+	rotated := newAPIKeys()
+	for k, s := range opts.Keys {
+		rotated[k] = s
+	}
+	for k := range rotated {
+		if _, ok := opts.Keys[k]; !ok {
+			opts.APIKey = k
+		}
+	}
+	opts.Keys = rotated
+	_, err = generateFiles(opts, dir, nil)
+	require.NoError(t, err)
+
+	// the answers generate a new deployment with its own key
+	replayed := &ServerOptions{LocalRedis: true}
+	require.NoError(t, loadServerOptions(path.Join(dir, deployFile), replayed))
+	require.NotEmpty(t, replayed.APIKey)
+	replayedDir := t.TempDir()
+	conf, err := generateFiles(replayed, replayedDir, nil)
+	require.NoError(t, err)
+	require.Len(t, conf.Keys, 1)
+	require.Empty(t, replayed.APIKey)
+
+	data, err := os.ReadFile(path.Join(replayedDir, "egress.yaml"))
+	require.NoError(t, err)
+	m := serviceAPIKeyRegexp.FindSubmatch(data)
+	require.NotNil(t, m)
+	require.Contains(t, conf.Keys, string(m[1]))
+}
+
+func TestParseKeyLabels(t *testing.T) {
+	labels, err := parseKeyLabels("service, product-a ,product-b")
+	require.NoError(t, err)
+	require.Equal(t, []string{"service", "product-a", "product-b"}, labels)
+
+	_, err = parseKeyLabels("service,,product-a")
+	require.Error(t, err)
+	_, err = parseKeyLabels("service, ")
+	require.Error(t, err)
+}
+
+func TestGenerateCaddyDNSChallenge(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.DNSProvider = "cloudflare"
+	opts.DNSCredentials = map[string]string{"api_token": "cloudflare-token"}
+	require.NoError(t, opts.Validate())
+	_, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(opts.Files.Docker)
+	require.NoError(t, err)
+	compose := struct {
+		Services map[string]struct {
+			Image string `yaml:"image"`
+		} `yaml:"services"`
+	}{}
+	require.NoError(t, yaml.Unmarshal(data, &compose))
+	require.Equal(t, "livekit/caddyl4:latest-cloudflare", compose.Services["caddy"].Image)
+
+	data, err = os.ReadFile(opts.Files.Caddy)
+	require.NoError(t, err)
+	caddy := struct {
+		Apps struct {
+			TLS struct {
+				Automation struct {
+					Policies []struct {
+						Issuers []struct {
+							Module     string `yaml:"module"`
+							Challenges struct {
+								DNS struct {
+									Provider map[string]string `yaml:"provider"`
+								} `yaml:"dns"`
+							} `yaml:"challenges"`
+						} `yaml:"issuers"`
+					} `yaml:"policies"`
+				} `yaml:"automation"`
+			} `yaml:"tls"`
+		} `yaml:"apps"`
+	}{}
+	require.NoError(t, yaml.Unmarshal(data, &caddy))
+	issuer := caddy.Apps.TLS.Automation.Policies[0].Issuers[0]
+	require.Equal(t, "acme", issuer.Module)
+	require.Equal(t, map[string]string{"name": "cloudflare", "api_token": "cloudflare-token"}, issuer.Challenges.DNS.Provider)
+}
+
+func TestGenerateRedisOptions(t *testing.T) {
+	testCases := []struct {
+		name  string
+		redis RedisOptions
+	}{
+		{
+			"tls", RedisOptions{Address: "redis.myhost.com:6380", Username: "livekit", Password: "redis-password", DB: 2, UseTLS: true},
+		},
+		{
+			"sentinel", RedisOptions{
+				Password:           "redis-password",
+				SentinelMasterName: "livekit",
+				SentinelAddresses:  []string{"sentinel-1.myhost.com:26379", "sentinel-2.myhost.com:26379"},
+				SentinelPassword:   "sentinel-password",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := testServerOptions()
+			opts.LocalRedis = false
+			opts.Redis = tc.redis
+			require.NoError(t, opts.Validate())
+			_, err := renderFiles(opts, dir)
+			require.NoError(t, err)
+			require.NoFileExists(t, path.Join(dir, "redis.conf"))
+
+			data, err := os.ReadFile(opts.Files.LiveKit)
+			require.NoError(t, err)
+			conf := &config.Config{}
+			require.NoError(t, yaml.Unmarshal(data, conf))
+
+			data, err = os.ReadFile(opts.Files.Egress)
+			require.NoError(t, err)
+			egressConf := &egressConfig{}
+			require.NoError(t, yaml.Unmarshal(data, egressConf))
+
+			// livekit.yaml and egress.yaml connect to the same Redis
+			for _, c := range []*redis.RedisConfig{&conf.Redis, egressConf.Redis} {
+				require.Equal(t, tc.redis.Address, c.Address)
+				require.Equal(t, tc.redis.Username, c.Username)
+				require.Equal(t, tc.redis.Password, c.Password)
+				require.Equal(t, tc.redis.DB, c.DB)
+				require.Equal(t, tc.redis.UseTLS, c.UseTLS)
+				require.Equal(t, tc.redis.SentinelMasterName, c.MasterName)
+				require.ElementsMatch(t, tc.redis.SentinelAddresses, c.SentinelAddresses)
+				require.Equal(t, tc.redis.SentinelPassword, c.SentinelPassword)
+			}
+		})
+	}
+}
+
+func TestGenerateBundledRedis(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	_, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, opts.Redis.Password)
+
+	data, err := os.ReadFile(opts.Files.RedisConf)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "bind 127.0.0.1 ::1\n")
+	require.Contains(t, string(data), "requirepass "+opts.Redis.Password+"\n")
+
+	data, err = os.ReadFile(opts.Files.LiveKit)
+	require.NoError(t, err)
+	conf := &config.Config{}
+	require.NoError(t, yaml.Unmarshal(data, conf))
+	require.Equal(t, "localhost:6379", conf.Redis.Address)
+	require.Equal(t, opts.Redis.Password, conf.Redis.Password)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+var (
+	serverImageRegexp = regexp.MustCompile(`image: livekit/livekit-server:(\S+)`)
+	// API key of the zerossl issuer in caddy.yaml
+	zeroSSLAPIKeyRegexp = regexp.MustCompile(`(?m)^\s*- module: zerossl\n\s*api_key: (\S+)`)
+	// API key of egress.yaml and ingress.yaml
+	serviceAPIKeyRegexp = regexp.MustCompile(`(?m)^api_key: (\S+)`)
+)
+
+// updateProduction regenerates an existing deployment directory, keeping its API keys and
+// any settings in livekit.yaml that the generator doesn't manage
+func updateProduction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("usage: generate update [options] <dir>")
+	}
+	baseDir := outputPath(c.Args().First())
+
+	opts, err := loadDeployment(c, baseDir)
+	if err != nil {
+		return err
+	}
+	if err = resolveServerOptions(c, opts, false); err != nil {
+		return err
+	}
+	testToken, err := resolveTestTokenOptions(c, false)
+	if err != nil {
+		return err
+	}
+
+	conf, err := rewriteDeployment(c, opts, baseDir)
+	if err != nil || conf == nil {
+		return err
+	}
+
+	fmt.Println("Updated deployment in directory:", c.Args().First())
+	fmt.Println("Existing API keys were kept, clients and backends do not need new credentials.")
+	fmt.Println()
+	return printInstructions(opts, conf, testToken)
+}
+
+// loadDeployment reads the answers and API keys of an existing deployment directory
+func loadDeployment(c *cli.Context, baseDir string) (*ServerOptions, error) {
+	opts := &ServerOptions{}
+	answers := path.Join(baseDir, deployFile)
+	if file := c.String(flagFromFile); file != "" {
+		answers = outputPath(file)
+	}
+	_, err := os.Stat(answers)
+	if err == nil {
+		if err = loadServerOptions(answers, opts); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	hasAnswers := err == nil
+
+	liveKitFile := path.Join(baseDir, "livekit.yaml")
+	if opts.IsCluster() {
+		// nodes only differ in settings the generator manages
+		liveKitFile = path.Join(baseDir, NodeName(opts.Nodes[0]), "livekit.yaml")
+	}
+	existing, existingMap, err := loadLiveKitConfig(liveKitFile)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAnswers {
+		// generated before answers were recorded
+		inferServerOptions(baseDir, existing, opts)
+	}
+	opts.Keys = existing.Keys
+	opts.existingLiveKit = existingMap
+	return opts, nil
+}
+
+// rewriteDeployment removes the files opts no longer produces and generates the others again.
+// On a dry run, it prints the changes instead and returns no config.
+func rewriteDeployment(c *cli.Context, opts *ServerOptions, baseDir string) (*config.Config, error) {
+	stale := staleFiles(opts)
+	if c.Bool(flagDryRun) {
+		return nil, dryRunProduction(opts, baseDir, stale)
+	}
+	return generateFiles(opts, baseDir, stale)
+}
+
+func loadLiveKitConfig(file string) (*config.Config, *yaml.Node, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	conf := &config.Config{}
+	if err = yaml.Unmarshal(data, conf); err != nil {
+		return nil, nil, fmt.Errorf("could not parse %s: %w", file, err)
+	}
+	if len(conf.Keys) == 0 {
+		// moved to .env by SecretsEnv
+		if conf.Keys, err = readEnvKeys(path.Dir(file)); errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("no api key found in %s", file)
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+	node := &yaml.Node{}
+	if err = yaml.Unmarshal(data, node); err != nil {
+		return nil, nil, fmt.Errorf("could not parse %s: %w", file, err)
+	}
+	return conf, node, nil
+}
+
+// inferServerOptions recovers the answers of a deployment from its generated files
+func inferServerOptions(baseDir string, conf *config.Config, opts *ServerOptions) {
+	exists := func(name string) bool {
+		_, err := os.Stat(path.Join(baseDir, name))
+		return err == nil
+	}
+
+	opts.Domain = path.Base(baseDir)
+	opts.TURNDomain = conf.TURN.Domain
+	opts.IncludeEgress = exists("egress.yaml")
+	opts.IncludeIngress = conf.Ingress.RTMPBaseURL != ""
+	if u, err := url.Parse(conf.Ingress.WHIPBaseURL); err == nil && u.Scheme == "https" {
+		opts.WHIPDomain = u.Hostname()
+	}
+	opts.DualStack = slices.Contains(conf.RTC.IPs.Excludes, ipv6LocalRanges[0])
+	opts.SecretsEnv = exists(envFile)
+	opts.LocalRedis = exists("redis.conf")
+	opts.Redis.Password = conf.Redis.Password
+	if !opts.LocalRedis {
+		opts.Redis = RedisOptions{
+			Address:            conf.Redis.Address,
+			Username:           conf.Redis.Username,
+			Password:           conf.Redis.Password,
+			DB:                 conf.Redis.DB,
+			UseTLS:             conf.Redis.UseTLS,
+			SentinelMasterName: conf.Redis.MasterName,
+			SentinelAddresses:  conf.Redis.SentinelAddresses,
+			SentinelPassword:   conf.Redis.SentinelPassword,
+		}
+	}
+
+	switch {
+	case exists("kubernetes.yaml"):
+		opts.Target = TargetKubernetes
+	case exists(helmServerValuesFile):
+		opts.Target = TargetHelm
+	case exists(systemdDir):
+		opts.Target = TargetSystemd
+	case exists(quadletDir):
+		opts.Target = TargetPodman
+	default:
+		opts.Target = TargetCompose
+	}
+
+	if len(conf.Keys) > 1 {
+		// a rotation was in progress, Egress and Ingress use the new key
+		for _, name := range []string{"egress.yaml", "ingress.yaml"} {
+			data, err := os.ReadFile(path.Join(baseDir, name))
+			if err != nil {
+				continue
+			}
+			if m := serviceAPIKeyRegexp.FindSubmatch(data); m != nil && conf.Keys[string(m[1])] != "" {
+				opts.APIKey = string(m[1])
+				break
+			}
+		}
+	}
+
+	opts.Terraform = exists(terraformDir)
+	opts.CloudInit = StartupScriptNone
+	for _, k := range startupScriptKinds {
+		if k != StartupScriptNone && exists(string(k)) {
+			opts.CloudInit = k
+		}
+	}
+
+	if data, err := os.ReadFile(path.Join(baseDir, "docker-compose.yaml")); err == nil {
+		if m := serverImageRegexp.FindSubmatch(data); m != nil {
+			opts.ServerVersion = string(m[1])
+		}
+	}
+	if data, err := os.ReadFile(path.Join(baseDir, "caddy.yaml")); err == nil {
+		if m := zeroSSLAPIKeyRegexp.FindSubmatch(data); m != nil {
+			opts.SSLIssuer = SSLIssuerZeroSSL
+			opts.ZeroSSLAPIKey = string(m[1])
+		}
+	} else if !opts.Target.IsKubernetes() {
+		opts.SSLIssuer = SSLIssuerExternal
+	}
+}
+
+// staleFiles lists files and directories of components that are no longer part of the deployment
+func staleFiles(opts *ServerOptions) []string {
+	var stale []string
+	if !opts.IncludeEgress {
+		stale = append(stale, "egress.yaml", helmEgressValuesFile)
+		stale = append(stale, unitFiles("livekit-egress")...)
+	}
+	if !opts.IncludeIngress {
+		stale = append(stale, "ingress.yaml", helmIngressValuesFile)
+		stale = append(stale, unitFiles("livekit-ingress")...)
+	}
+	if !opts.LocalRedis {
+		stale = append(stale, "redis.conf")
+		stale = append(stale, unitFiles("livekit-redis")...)
+	}
+	for _, k := range startupScriptKinds {
+		if k != StartupScriptNone && k != opts.CloudInit {
+			stale = append(stale, string(k))
+		}
+	}
+	if !opts.UsesCaddy() {
+		stale = append(stale, "caddy.yaml")
+		stale = append(stale, unitFiles("livekit-caddy")...)
+	}
+	if !opts.SecretsEnv {
+		stale = append(stale, envFile)
+	}
+	if opts.Target != TargetCompose {
+		stale = append(stale, "docker-compose.yaml")
+	}
+	if opts.Target != TargetSystemd {
+		stale = append(stale, systemdDir)
+	}
+	if opts.Target != TargetPodman {
+		stale = append(stale, quadletDir)
+	}
+	if opts.Target.IsKubernetes() {
+		stale = append(stale, firewallDir)
+	}
+	if !opts.Terraform {
+		stale = append(stale, terraformDir)
+	}
+	if opts.Target != TargetKubernetes {
+		stale = append(stale, "kubernetes.yaml")
+	}
+	if opts.Target != TargetHelm {
+		stale = append(stale, helmServerValuesFile, helmEgressValuesFile, helmIngressValuesFile)
+	}
+	if opts.IsCluster() {
+		// single server files, now in the directory of each node
+		stale = append(stale, "livekit.yaml", "egress.yaml", "ingress.yaml", "redis.conf", "caddy.yaml", "docker-compose.yaml", string(opts.CloudInit))
+	} else {
+		stale = append(stale, clusterSummaryFile)
+	}
+	return stale
+}
+
+// generatedLiveKitPaths are the settings of livekit.yaml written by generateLiveKit. They are dropped from the
+// existing config before merging, so that a setting the new options leave out isn't carried over from it.
+var generatedLiveKitPaths = [][]string{
+	{"port"},
+	{"bind_addresses"},
+	{"keys"},
+	{"redis"},
+	{"rtc", "tcp_port"},
+	{"rtc", "port_range_start"},
+	{"rtc", "port_range_end"},
+	{"rtc", "use_external_ip"},
+	{"rtc", "ips", "excludes"},
+	{"turn", "enabled"},
+	{"turn", "domain"},
+	{"turn", "cert_file"},
+	{"turn", "key_file"},
+	{"turn", "tls_port"},
+	{"turn", "udp_port"},
+	{"turn", "external_tls"},
+	{"ingress", "rtmp_base_url"},
+	{"ingress", "whip_base_url"},
+}
+
+// mergeLiveKitConfig overlays the generated config onto the existing one. Generated settings replace
+// existing ones, while settings the generator doesn't manage are kept from the existing config.
+func mergeLiveKitConfig(opts *ServerOptions, generated []byte) ([]byte, error) {
+	overlay := &yaml.Node{}
+	if err := yaml.Unmarshal(generated, overlay); err != nil {
+		return nil, err
+	}
+	base := opts.existingLiveKit
+	if !opts.IncludeIngress {
+		base = withoutPath(base, "ingress")
+	}
+	for _, p := range generatedLiveKitPaths {
+		base = withoutPath(base, p...)
+	}
+	mergeNodes(documentRoot(base), documentRoot(overlay))
+	return yaml.Marshal(overlay)
+}
+
+func mergeNodes(base, overlay *yaml.Node) {
+	if base == nil || overlay == nil || base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(base.Content); i += 2 {
+		key, value := base.Content[i], base.Content[i+1]
+		if existing := mappingValue(overlay, key.Value); existing != nil {
+			mergeNodes(value, existing)
+			continue
+		}
+		overlay.Content = append(overlay.Content, key, value)
+	}
+}
+
+func documentRoot(n *yaml.Node) *yaml.Node {
+	if n != nil && n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		return n.Content[0]
+	}
+	return n
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// withoutPath returns a copy of the document without the nested key, mappings left empty are removed as well
+func withoutPath(n *yaml.Node, path ...string) *yaml.Node {
+	root := documentRoot(n)
+	if root == nil || root.Kind != yaml.MappingNode || len(path) == 0 {
+		return n
+	}
+	filtered := *root
+	filtered.Content = nil
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Value == path[0] {
+			if len(path) == 1 {
+				continue
+			}
+			value = withoutPath(value, path[1:]...)
+			if value.Kind == yaml.MappingNode && len(value.Content) == 0 {
+				continue
+			}
+		}
+		filtered.Content = append(filtered.Content, key, value)
+	}
+	return &filtered
+}
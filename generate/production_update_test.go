@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestMergeLiveKitConfig(t *testing.T) {
+	existing := &yaml.Node{}
+	require.NoError(t, yaml.Unmarshal([]byte(`keys:
+  old: secret
+turn:
+  domain: old.myhost.com
+  relay_range_start: 30000
+ingress:
+  rtmp_base_url: rtmp://old.myhost.com/x
+room:
+  empty_timeout: 30
+`), existing))
+	opts := &ServerOptions{existingLiveKit: existing}
+
+	merged, err := mergeLiveKitConfig(opts, []byte(`keys:
+  new: secret
+turn:
+  domain: new.myhost.com
+`))
+	require.NoError(t, err)
+	require.Equal(t, `keys:
+    new: secret
+turn:
+    domain: new.myhost.com
+    relay_range_start: 30000
+room:
+    empty_timeout: 30
+`, string(merged))
+}
+
+func TestUpdateRemovesGeneratedSettings(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.Target = TargetKubernetes
+	opts.DualStack = true
+	_, err := generateFiles(opts, dir, nil)
+	require.NoError(t, err)
+
+	// a setting the generator doesn't manage
+	file := path.Join(dir, "livekit.yaml")
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, append(data, []byte("room:\n  empty_timeout: 30\n")...), secretFilePerms))
+
+	existing, existingMap, err := loadLiveKitConfig(file)
+	require.NoError(t, err)
+	require.NotEmpty(t, existing.TURN.CertFile)
+	require.NotEmpty(t, existing.RTC.IPs.Excludes)
+	opts.Keys = existing.Keys
+	opts.existingLiveKit = existingMap
+	opts.Target = TargetCompose
+	opts.DualStack = false
+	opts.IncludeIngress = false
+	_, err = generateFiles(opts, dir, staleFiles(opts))
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(file)
+	require.NoError(t, err)
+	updated := &config.Config{}
+	require.NoError(t, yaml.Unmarshal(data, updated))
+	require.Empty(t, updated.TURN.CertFile)
+	require.Empty(t, updated.TURN.KeyFile)
+	require.True(t, updated.TURN.ExternalTLS)
+	require.Empty(t, updated.RTC.IPs.Excludes)
+	require.Empty(t, updated.Ingress.RTMPBaseURL)
+	require.Equal(t, existing.Keys, updated.Keys)
+	require.Equal(t, uint32(30), updated.Room.EmptyTimeout)
+}
+
+func TestUpdateToBundledRedis(t *testing.T) {
+	opts := testServerOptions()
+	opts.LocalRedis = false
+	opts.Redis = RedisOptions{
+		Password:           "external-password",
+		UseTLS:             true,
+		SentinelMasterName: "livekit",
+		SentinelAddresses:  []string{"sentinel-1.myhost.com:26379"},
+		SentinelPassword:   "sentinel-password",
+	}
+	require.NoError(t, opts.Validate())
+
+	c := testFlagContext(t, productionFlags, "--external-redis=false")
+	require.NoError(t, resolveServerOptions(c, opts, false))
+	require.True(t, opts.LocalRedis)
+	require.Empty(t, opts.Redis.SentinelAddresses)
+	require.False(t, opts.Redis.UseTLS)
+	// the bundled Redis gets its own password
+	require.NotEmpty(t, opts.Redis.Password)
+	require.NotEqual(t, "external-password", opts.Redis.Password)
+}
+
+func TestZeroSSLAPIKeyRegexp(t *testing.T) {
+	dir := t.TempDir()
+	opts := testServerOptions()
+	opts.SSLIssuer = SSLIssuerZeroSSL
+	opts.ZeroSSLAPIKey = "zerossl-key"
+	_, err := renderFiles(opts, dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path.Join(dir, "caddy.yaml"))
+	require.NoError(t, err)
+	m := zeroSSLAPIKeyRegexp.FindSubmatch(data)
+	require.NotNil(t, m)
+	require.Equal(t, "zerossl-key", string(m[1]))
+
+	// only the key of the zerossl issuer
+	require.Nil(t, zeroSSLAPIKeyRegexp.FindSubmatch([]byte("    api_key: other\n")))
+}
@@ -6,22 +6,42 @@ const CaddyConfigTemplate = `logging:
       level: INFO
 storage:
   "module": "file_system"
-  "root": "/data"
+  "root": "{{.CaddyDataDir}}"
 apps:
   tls:
     certificates:
+{{- if .Certificates }}
+      load_files:
+{{- range .Certificates }}
+        - certificate: {{$.CaddyCertsDir}}/{{.Domain}}.crt
+          key: {{$.CaddyCertsDir}}/{{.Domain}}.key
+{{- end }}
+{{- else }}
       automate:
         - {{.Domain}}
         - {{.TURNDomain}}
 {{- if .WHIPDomain }}
         - {{.WHIPDomain}}
 {{- end }}
+{{- end }}
 {{- if .ZeroSSLAPIKey }}
     automation:
       policies:
         - issuers:
           - module: zerossl
             api_key: {{.ZeroSSLAPIKey}}
+{{- else if .DNSProvider }}
+    automation:
+      policies:
+        - issuers:
+          - module: acme
+            challenges:
+              dns:
+                provider:
+                  name: {{.DNSProvider}}
+{{- range $field, $value := .DNSCredentials }}
+                  {{$field}}: {{printf "%q" $value}}
+{{- end }}
 {{- end }}
   layer4:
     servers:
@@ -3,19 +3,27 @@ package templates
 const DockerComposeBaseTemplate = `# This docker-compose requires host networking, which is only available on Linux
 # This compose will not function correctly on Mac or Windows
 services:
+{{- if .UsesCaddy }}
   caddy:
-    image: livekit/caddyl4
+    image: {{.CaddyImage}}
     command: run --config /etc/caddy.yaml --adapter yaml
     restart: unless-stopped
     network_mode: "host"
     volumes:
       - ./caddy.yaml:/etc/caddy.yaml
       - ./caddy_data:/data
+{{- if .Certificates }}
+      - ./certs:{{.CaddyCertsDir}}
+{{- end }}
+{{- end }}
   livekit:
     image: livekit/livekit-server:{{.ServerVersion}}
     command: --config /etc/livekit.yaml
     restart: unless-stopped
     network_mode: "host"
+{{- if .SecretsEnv }}
+    env_file: .env
+{{- end }}
     volumes:
       - ./livekit.yaml:/etc/livekit.yaml
 `
@@ -34,6 +42,9 @@ const DockerComposeEgressTemplate = `  egress:
     restart: unless-stopped
     environment:
       - EGRESS_CONFIG_FILE=/etc/egress.yaml
+{{- if .SecretsEnv }}
+    env_file: .env
+{{- end }}
     network_mode: "host"
     volumes:
       - ./egress.yaml:/etc/egress.yaml
@@ -46,6 +57,9 @@ const DockerComposeIngressTemplate = `  ingress:
     restart: unless-stopped
     environment:
       - INGRESS_CONFIG_FILE=/etc/ingress.yaml
+{{- if .SecretsEnv }}
+    env_file: .env
+{{- end }}
     network_mode: "host"
     volumes:
       - ./ingress.yaml:/etc/ingress.yaml
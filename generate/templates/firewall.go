@@ -0,0 +1,115 @@
+package templates
+
+// Firewall scripts opening the ports of a server, rendered from the same port list as the instructions
+
+const FirewallUFWTemplate = `#!/bin/sh
+# Opens the ports required by LiveKit with ufw, and enables it.
+# SSH is allowed as well, so that enabling ufw doesn't lock you out.
+set -e
+
+if ! command -v ufw >/dev/null; then
+  apt-get update && apt-get install -y ufw
+fi
+
+ufw allow 22/tcp comment 'SSH'
+{{- range .Ports }}
+ufw allow {{.Range ":"}}/{{.Protocol}} comment '{{.Description}}'
+{{- end }}
+ufw --force enable
+`
+
+const FirewallFirewalldTemplate = `#!/bin/sh
+# Opens the ports required by LiveKit with firewalld, in the default zone.
+# The default zone already allows SSH.
+set -e
+
+if ! command -v firewall-cmd >/dev/null; then
+  if command -v apt-get >/dev/null; then
+    apt-get update && apt-get install -y firewalld
+  else
+    dnf install -y firewalld || yum install -y firewalld
+  fi
+fi
+systemctl enable --now firewalld
+{{ range .Ports }}
+# {{.Description}}
+firewall-cmd --permanent --add-port={{.Firewall}}
+{{- end }}
+firewall-cmd --reload
+`
+
+const FirewallNftablesTemplate = `#!/bin/sh
+# Drops incoming traffic other than SSH and the ports required by LiveKit with nftables.
+# The rules are kept in their own table, saved to /etc/nftables/livekit.nft and loaded at boot by the nftables service.
+set -e
+
+if ! command -v nft >/dev/null; then
+  if command -v apt-get >/dev/null; then
+    apt-get update && apt-get install -y nftables
+  else
+    dnf install -y nftables || yum install -y nftables
+  fi
+fi
+
+mkdir -p /etc/nftables
+cat << "NFT" > /etc/nftables/livekit.nft
+# replaces the table when loaded again
+table inet livekit
+delete table inet livekit
+
+table inet livekit {
+  chain input {
+    type filter hook input priority 0; policy drop;
+    ct state established,related accept
+    ct state invalid drop
+    iif lo accept
+    meta l4proto { icmp, ipv6-icmp } accept
+    tcp dport 22 accept comment "SSH"
+{{- range .Ports }}
+    {{.Protocol}} dport {{.Range "-"}} accept comment "{{.Description}}"
+{{- end }}
+  }
+}
+NFT
+nft -f /etc/nftables/livekit.nft
+
+# Debian and the RHEL family load different files at boot
+for conf in /etc/nftables.conf /etc/sysconfig/nftables.conf; do
+  if [ -f "$conf" ] && ! grep -q /etc/nftables/livekit.nft "$conf"; then
+    echo 'include "/etc/nftables/livekit.nft"' >> "$conf"
+  fi
+done
+systemctl enable nftables 2>/dev/null || true
+`
+
+// GCPFirewallTemplate creates a VPC firewall rule for instances with the livekit network tag
+const GCPFirewallTemplate = `#!/bin/sh
+# Opens the ports required by LiveKit in a GCP VPC network, for instances tagged with TAG.
+# NETWORK=default TAG=livekit ./gcp_firewall.sh
+set -e
+
+NETWORK=${NETWORK:-default}
+TAG=${TAG:-livekit}
+
+gcloud compute firewall-rules create {{.Name}} \
+  --network "$NETWORK" \
+  --direction INGRESS \
+  --action ALLOW \
+  --rules {{range $i, $p := .Ports}}{{if $i}},{{end}}{{$p.Protocol}}:{{$p.Range "-"}}{{end}} \
+  --source-ranges 0.0.0.0/0 \
+  --target-tags "$TAG"
+{{- if .DualStack }}
+
+# IPv4 and IPv6 ranges can't be mixed in a rule
+gcloud compute firewall-rules create {{.Name}}-ipv6 \
+  --network "$NETWORK" \
+  --direction INGRESS \
+  --action ALLOW \
+  --rules {{range $i, $p := .Ports}}{{if $i}},{{end}}{{$p.Protocol}}:{{$p.Range "-"}}{{end}} \
+  --source-ranges ::/0 \
+  --target-tags "$TAG"
+{{- end }}
+
+# add the tag to an instance with:
+# gcloud compute instances add-tags <instance> --tags "$TAG"
+`
@@ -0,0 +1,396 @@
+package templates
+
+// KubernetesTemplate renders all resources of a deployment into a single manifest.
+// LiveKit and Ingress use host networking, the ICE port range is outside what NodePort services allow.
+const KubernetesTemplate = `# Requires cert-manager (https://cert-manager.io) and an ingress controller to be installed in the cluster
+# Apply with: kubectl apply -f kubernetes.yaml
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{.Namespace}}
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: livekit-keys
+  namespace: {{.Namespace}}
+type: Opaque
+stringData:
+  LIVEKIT_KEYS: {{.LiveKitKeys}}
+  LIVEKIT_API_KEY: "{{.APIKey}}"
+  LIVEKIT_API_SECRET: "{{.APISecret}}"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: livekit-config
+  namespace: {{.Namespace}}
+type: Opaque
+stringData:
+  livekit.yaml: |
+{{.LiveKitConfig}}
+{{- if .DNSCredentials }}
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: livekit-dns-credentials
+  # cert-manager reads the secrets of a ClusterIssuer from its own namespace
+  namespace: cert-manager
+type: Opaque
+stringData:
+{{- range $field, $value := .DNSCredentials }}
+  {{$field}}: {{printf "%q" $value}}
+{{- end }}
+{{- end }}
+---
+apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: livekit-letsencrypt
+spec:
+  acme:
+    server: https://acme-v02.api.letsencrypt.org/directory
+    privateKeySecretRef:
+      name: livekit-letsencrypt
+    solvers:
+      - http01:
+          ingress: {}
+{{- if .DNSProvider }}
+      # the TURN domain points to the node running LiveKit, HTTP-01 challenges wouldn't reach the ingress controller
+      - selector:
+          dnsNames:
+            - {{.TURNDomain}}
+        dns01:
+{{- if eq .DNSProvider "cloudflare" }}
+          cloudflare:
+            apiTokenSecretRef:
+              name: livekit-dns-credentials
+              key: api_token
+{{- else if eq .DNSProvider "route53" }}
+          route53:
+{{- with index .DNSCredentials "region" }}
+            region: {{.}}
+{{- end }}
+{{- with index .DNSCredentials "access_key_id" }}
+            accessKeyID: {{.}}
+            secretAccessKeySecretRef:
+              name: livekit-dns-credentials
+              key: secret_access_key
+{{- end }}
+{{- else if eq .DNSProvider "digitalocean" }}
+          digitalocean:
+            tokenSecretRef:
+              name: livekit-dns-credentials
+              key: auth_token
+{{- end }}
+{{- end }}
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: livekit-turn
+  namespace: {{.Namespace}}
+spec:
+  secretName: livekit-turn-tls
+  dnsNames:
+    - {{.TURNDomain}}
+  issuerRef:
+    name: livekit-letsencrypt
+    kind: ClusterIssuer
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: livekit
+  namespace: {{.Namespace}}
+spec:
+  replicas: 1
+  strategy:
+    # host ports cannot be shared during a rolling update
+    type: Recreate
+  selector:
+    matchLabels:
+      app: livekit
+  template:
+    metadata:
+      labels:
+        app: livekit
+    spec:
+      hostNetwork: true
+      dnsPolicy: ClusterFirstWithHostNet
+      terminationGracePeriodSeconds: 18000
+      containers:
+        - name: livekit
+          image: livekit/livekit-server:{{.ServerVersion}}
+          args: ["--config", "/etc/livekit/livekit.yaml"]
+          env:
+            - name: LIVEKIT_KEYS
+              valueFrom:
+                secretKeyRef:
+                  name: livekit-keys
+                  key: LIVEKIT_KEYS
+          ports:
+            - name: http
+              containerPort: {{.Port}}
+              protocol: TCP
+            - name: rtc-tcp
+              containerPort: {{.RTCTCPPort}}
+              protocol: TCP
+            - name: turn-tls
+              containerPort: {{.TURNTLSPort}}
+              protocol: TCP
+            - name: turn-udp
+              containerPort: {{.TURNUDPPort}}
+              protocol: UDP
+          volumeMounts:
+            - name: config
+              mountPath: /etc/livekit
+            - name: turn-tls
+              mountPath: {{.TURNCertDir}}
+              readOnly: true
+      volumes:
+        - name: config
+          secret:
+            secretName: livekit-config
+        - name: turn-tls
+          secret:
+            secretName: livekit-turn-tls
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: livekit
+  namespace: {{.Namespace}}
+spec:
+  type: ClusterIP
+  selector:
+    app: livekit
+  ports:
+    - name: http
+      port: {{.Port}}
+      targetPort: {{.Port}}
+      protocol: TCP
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: livekit
+  namespace: {{.Namespace}}
+  annotations:
+    cert-manager.io/cluster-issuer: livekit-letsencrypt
+    # long-lived WebSocket connections for ingress-nginx
+    nginx.ingress.kubernetes.io/proxy-read-timeout: "3600"
+    nginx.ingress.kubernetes.io/proxy-send-timeout: "3600"
+spec:
+  tls:
+    - hosts:
+        - {{.Domain}}
+{{- if .WHIPDomain }}
+        - {{.WHIPDomain}}
+{{- end }}
+      secretName: livekit-tls
+  rules:
+    - host: {{.Domain}}
+      http:
+        paths:
+          - path: /
+            pathType: Prefix
+            backend:
+              service:
+                name: livekit
+                port:
+                  number: {{.Port}}
+{{- if .WHIPDomain }}
+    - host: {{.WHIPDomain}}
+      http:
+        paths:
+          - path: /
+            pathType: Prefix
+            backend:
+              service:
+                name: ingress
+                port:
+                  number: {{.WHIPPort}}
+{{- end }}
+`
+
+const KubernetesRedisTemplate = `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: redis-config
+  namespace: {{.Namespace}}
+type: Opaque
+stringData:
+  redis.conf: |
+{{.RedisConf}}
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: redis
+  namespace: {{.Namespace}}
+spec:
+  serviceName: redis
+  replicas: 1
+  selector:
+    matchLabels:
+      app: redis
+  template:
+    metadata:
+      labels:
+        app: redis
+    spec:
+      containers:
+        - name: redis
+          image: redis:7-alpine
+          # other pods reach Redis through its service rather than the loopback interface
+          args: ["redis-server", "/etc/redis/redis.conf", "--bind", "0.0.0.0", "--protected-mode", "no"]
+          ports:
+            - name: redis
+              containerPort: 6379
+          volumeMounts:
+            - name: config
+              mountPath: /etc/redis
+      volumes:
+        - name: config
+          secret:
+            secretName: redis-config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: redis
+  namespace: {{.Namespace}}
+spec:
+  selector:
+    app: redis
+  ports:
+    - name: redis
+      port: 6379
+      targetPort: 6379
+`
+
+const KubernetesEgressTemplate = `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: egress-config
+  namespace: {{.Namespace}}
+type: Opaque
+stringData:
+  egress.yaml: |
+{{.EgressConfig}}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: egress
+  namespace: {{.Namespace}}
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: egress
+  template:
+    metadata:
+      labels:
+        app: egress
+    spec:
+      terminationGracePeriodSeconds: 3600
+      containers:
+        - name: egress
+          image: livekit/egress:latest
+          env:
+            - name: EGRESS_CONFIG_FILE
+              value: /etc/egress/egress.yaml
+          envFrom:
+            - secretRef:
+                name: livekit-keys
+          securityContext:
+            capabilities:
+              add:
+                - SYS_ADMIN
+          volumeMounts:
+            - name: config
+              mountPath: /etc/egress
+      volumes:
+        - name: config
+          secret:
+            secretName: egress-config
+`
+
+const KubernetesIngressTemplate = `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: ingress-config
+  namespace: {{.Namespace}}
+type: Opaque
+stringData:
+  ingress.yaml: |
+{{.IngressConfig}}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ingress
+  namespace: {{.Namespace}}
+spec:
+  replicas: 1
+  strategy:
+    # host ports cannot be shared during a rolling update
+    type: Recreate
+  selector:
+    matchLabels:
+      app: ingress
+  template:
+    metadata:
+      labels:
+        app: ingress
+    spec:
+      hostNetwork: true
+      dnsPolicy: ClusterFirstWithHostNet
+      containers:
+        - name: ingress
+          image: livekit/ingress:latest
+          env:
+            - name: INGRESS_CONFIG_FILE
+              value: /etc/ingress/ingress.yaml
+          envFrom:
+            - secretRef:
+                name: livekit-keys
+          ports:
+            - name: rtmp
+              containerPort: {{.RTMPPort}}
+              protocol: TCP
+            - name: whip
+              containerPort: {{.WHIPPort}}
+              protocol: TCP
+            - name: whip-udp
+              containerPort: {{.IngressUDPPort}}
+              protocol: UDP
+          volumeMounts:
+            - name: config
+              mountPath: /etc/ingress
+      volumes:
+        - name: config
+          secret:
+            secretName: ingress-config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: ingress
+  namespace: {{.Namespace}}
+spec:
+  type: ClusterIP
+  selector:
+    app: ingress
+  ports:
+    - name: whip
+      port: {{.WHIPPort}}
+      targetPort: {{.WHIPPort}}
+      protocol: TCP
+`
@@ -0,0 +1,197 @@
+package templates
+
+// Quadlet units of the podman target, mirroring the services of the docker-compose file
+
+const PodmanLiveKitTemplate = `[Unit]
+Description=LiveKit Server
+{{- if .LocalRedis }}
+Requires=livekit-redis.service
+After=livekit-redis.service
+{{- end }}
+
+[Container]
+Image=docker.io/livekit/livekit-server:{{.ServerVersion}}
+Exec=--config /etc/livekit.yaml
+Network=host
+Volume={{.InstallPrefix}}/livekit.yaml:/etc/livekit.yaml:Z
+
+[Service]
+LimitNOFILE=500000
+Restart=always
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+const PodmanCaddyTemplate = `[Unit]
+Description=Caddy for LiveKit
+
+[Container]
+Image=docker.io/{{.CaddyImage}}
+Exec=run --config /etc/caddy.yaml --adapter yaml
+Network=host
+Volume={{.InstallPrefix}}/caddy.yaml:/etc/caddy.yaml:Z
+Volume={{.InstallPrefix}}/caddy_data:/data:Z
+{{- if .Certificates }}
+Volume={{.InstallPrefix}}/certs:{{.CaddyCertsDir}}:Z
+{{- end }}
+
+[Service]
+Restart=always
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+const PodmanRedisTemplate = `[Unit]
+Description=Redis for LiveKit
+
+[Container]
+Image=docker.io/library/redis:7-alpine
+Exec=redis-server /etc/redis.conf
+Network=host
+Volume={{.InstallPrefix}}/redis.conf:/etc/redis.conf:Z
+
+[Service]
+# the config holds the password, only the redis user of the image can read it, through the user namespace with rootless Podman
+ExecStartPre=/bin/sh -c 'uid=$$(podman run --rm --entrypoint id docker.io/library/redis:7-alpine -u redis); if [ $$(id -u) = 0 ]; then chown $$uid {{.InstallPrefix}}/redis.conf; else podman unshare chown $$uid {{.InstallPrefix}}/redis.conf; fi'
+Restart=always
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+const PodmanEgressTemplate = `[Unit]
+Description=LiveKit Egress
+
+[Container]
+Image=docker.io/livekit/egress:latest
+Environment=EGRESS_CONFIG_FILE=/etc/egress.yaml
+Network=host
+Volume={{.InstallPrefix}}/egress.yaml:/etc/egress.yaml:Z
+AddCapability=CAP_SYS_ADMIN
+
+[Service]
+# the config holds the API secret, only the user of the image can read it, through the user namespace with rootless Podman
+ExecStartPre=/bin/sh -c 'uid=$$(podman run --rm --entrypoint id docker.io/livekit/egress:latest -u); if [ $$(id -u) = 0 ]; then chown $$uid {{.InstallPrefix}}/egress.yaml; else podman unshare chown $$uid {{.InstallPrefix}}/egress.yaml; fi'
+Restart=always
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+const PodmanIngressTemplate = `[Unit]
+Description=LiveKit Ingress
+
+[Container]
+Image=docker.io/livekit/ingress:latest
+Environment=INGRESS_CONFIG_FILE=/etc/ingress.yaml
+Network=host
+Volume={{.InstallPrefix}}/ingress.yaml:/etc/ingress.yaml:Z
+
+[Service]
+# the config holds the API secret, only the user of the image can read it, through the user namespace with rootless Podman
+ExecStartPre=/bin/sh -c 'uid=$$(podman run --rm --entrypoint id docker.io/livekit/ingress:latest -u); if [ $$(id -u) = 0 ]; then chown $$uid {{.InstallPrefix}}/ingress.yaml; else podman unshare chown $$uid {{.InstallPrefix}}/ingress.yaml; fi'
+Restart=always
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+const PodmanStartupScriptTemplate = `#!/bin/sh
+# This script will write all of your configurations to {{.InstallPrefix}}.
+# It'll also install the Quadlet units to /etc/containers/systemd, which requires Podman 4.4 or later.
+# LiveKit will be started automatically at machine startup.
+
+# create directories for LiveKit
+mkdir -p {{.InstallPrefix}}/caddy_data
+# the configs hold API secrets and passwords, only root can enter the install directory
+chmod 700 {{.InstallPrefix}}
+mkdir -p /etc/containers/systemd
+
+# Podman will need to be installed on the machine
+if command -v apt-get >/dev/null; then
+  apt-get update && apt-get install -y podman
+else
+  dnf install -y podman || yum install -y podman
+fi
+
+# livekit config
+cat << EOF > {{.InstallPrefix}}/livekit.yaml
+{{.LiveKitConfig}}
+EOF
+chmod 600 {{.InstallPrefix}}/livekit.yaml
+{{- if .CaddyConfig }}
+
+# caddy config
+cat << EOF > {{.InstallPrefix}}/caddy.yaml
+{{.CaddyConfig}}
+EOF
+chmod 600 {{.InstallPrefix}}/caddy.yaml
+
+# update ip script
+cat << "EOF" > {{.InstallPrefix}}/update_ip.sh
+{{.UpdateIPScript}}
+EOF
+{{- end }}
+
+{{- if .RedisConf }}
+# redis config
+cat << EOF > {{.InstallPrefix}}/redis.conf
+{{.RedisConf}}
+EOF
+chmod 600 {{.InstallPrefix}}/redis.conf
+{{- end }}
+
+{{- if .EgressConf }}
+# egress config
+cat << EOF > {{.InstallPrefix}}/egress.yaml
+{{.EgressConf}}
+EOF
+chmod 600 {{.InstallPrefix}}/egress.yaml
+{{- end }}
+
+{{- if .IngressConf }}
+# ingress config
+cat << EOF > {{.InstallPrefix}}/ingress.yaml
+{{.IngressConf}}
+EOF
+chmod 600 {{.InstallPrefix}}/ingress.yaml
+{{- end }}
+
+{{- range .Certificates }}
+# certificate
+mkdir -p {{$.InstallPrefix}}/certs
+cat << EOF > {{$.InstallPrefix}}/{{.Path}}
+{{.Content}}
+EOF
+chmod 600 {{$.InstallPrefix}}/{{.Path}}
+{{- end }}
+
+{{- if .FirewallScript }}
+# firewall
+cat << "EOF" > {{.InstallPrefix}}/firewall.sh
+{{.FirewallScript}}
+EOF
+chmod 755 {{.InstallPrefix}}/firewall.sh
+{{.InstallPrefix}}/firewall.sh
+{{- end }}
+
+{{- range .Units }}
+# quadlet unit
+cat << EOF > /etc/containers/systemd/{{.Path}}
+{{.Content}}
+EOF
+{{- end }}
+{{- if .UpdateIPScript }}
+
+chmod 755 {{.InstallPrefix}}/update_ip.sh
+{{.InstallPrefix}}/update_ip.sh
+{{- end }}
+
+# quadlet generates the services on reload, they are started at boot through their Install section
+systemctl daemon-reload
+{{- range .Units }}
+systemctl start {{.ServiceName}}
+{{- end }}
+`
@@ -1,8 +1,11 @@
 package templates
 
-const RedisConf = `bind 127.0.0.1 ::1
+const RedisConfTemplate = `bind 127.0.0.1 ::1
 protected-mode yes
 port 6379
 timeout 0
 tcp-keepalive 300
+{{- if .Redis.Password }}
+requirepass {{.Redis.Password}}
+{{- end }}
 `
@@ -12,47 +12,79 @@ packages:
 
 bootcmd:
   - mkdir -p {{.InstallPrefix}}/caddy_data
+  # the configs hold API secrets and passwords, only root can enter the install directory
+  - chmod 700 {{.InstallPrefix}}
   - mkdir -p /usr/local/bin
 
 write_files:
   - path: {{.InstallPrefix}}/livekit.yaml
+    permissions: '0600'
     content: |
 {{.LiveKitConfig}}
+{{- if .CaddyConfig }}
   - path: {{.InstallPrefix}}/caddy.yaml
+    permissions: '0600'
     content: |
 {{.CaddyConfig}}
   - path: {{.InstallPrefix}}/update_ip.sh
     content: |
 {{.UpdateIPScript}}
+{{- end }}
   - path: {{.InstallPrefix}}/docker-compose.yaml
     content: |
 {{.DockerComposeConfig}}
+{{- if .EnvFile }}
+  - path: {{.InstallPrefix}}/.env
+    permissions: '0600'
+    content: |
+{{.EnvFile}}
+{{- end }}
   - path: /etc/systemd/system/livekit-docker.service
     content: |
 {{.SystemService}}
 {{- if .RedisConf }}
   - path: {{.InstallPrefix}}/redis.conf
+    permissions: '0600'
     content: |
 {{.RedisConf}}
 {{- end }}
 {{- if .EgressConf }}
   - path: {{.InstallPrefix}}/egress.yaml
+    permissions: '0600'
     content: |
 {{.EgressConf}}
 {{- end }}
 {{- if .IngressConf }}
   - path: {{.InstallPrefix}}/ingress.yaml
+    permissions: '0600'
     content: |
 {{.IngressConf}}
 {{- end }}
+{{- range .Certificates }}
+  - path: {{$.InstallPrefix}}/{{.Path}}
+    permissions: '0600'
+    content: |
+{{.Content}}
+{{- end }}
+{{- if .FirewallScript }}
+  - path: {{.InstallPrefix}}/firewall.sh
+    permissions: '0755'
+    content: |
+{{.FirewallScript}}
+{{- end }}
 
 runcmd:
   - curl -L "https://github.com/docker/compose/releases/download/v2.20.2/docker-compose-$(uname -s)-$(uname -m)" -o /usr/local/bin/docker-compose
   - chmod 755 /usr/local/bin/docker-compose
-  - chmod 755 {{.InstallPrefix}}/update_ip.sh
-  - {{.InstallPrefix}}/update_ip.sh
+{{- if .FirewallScript }}
+  - {{.InstallPrefix}}/firewall.sh
+{{- end }}
   - systemctl enable docker
   - systemctl start docker
+{{- if .UpdateIPScript }}
+  - chmod 755 {{.InstallPrefix}}/update_ip.sh
+  - {{.InstallPrefix}}/update_ip.sh
+{{- end }}
   - systemctl enable livekit-docker
   - systemctl start livekit-docker
 `
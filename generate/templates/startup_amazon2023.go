@@ -0,0 +1,96 @@
+package templates
+
+// CloudInitAmazon2023Template installs Docker from the distribution, and the compose plugin from its release,
+// for Amazon Linux 2023 which doesn't package it
+const CloudInitAmazon2023Template = `#cloud-config
+# This file is used as a user-data script to start a VM
+# It'll upload configs to the right location and install LiveKit as a systemd service
+# LiveKit will be started automatically at machine startup
+package_update: true
+package_upgrade: true
+
+packages:
+  - docker
+  - policycoreutils-python-utils
+
+bootcmd:
+  - mkdir -p {{.InstallPrefix}}/caddy_data
+  # the configs hold API secrets and passwords, only root can enter the install directory
+  - chmod 700 {{.InstallPrefix}}
+
+write_files:
+  - path: {{.InstallPrefix}}/livekit.yaml
+    permissions: '0600'
+    content: |
+{{.LiveKitConfig}}
+{{- if .CaddyConfig }}
+  - path: {{.InstallPrefix}}/caddy.yaml
+    permissions: '0600'
+    content: |
+{{.CaddyConfig}}
+  - path: {{.InstallPrefix}}/update_ip.sh
+    content: |
+{{.UpdateIPScript}}
+{{- end }}
+  - path: {{.InstallPrefix}}/docker-compose.yaml
+    content: |
+{{.DockerComposeConfig}}
+{{- if .EnvFile }}
+  - path: {{.InstallPrefix}}/.env
+    permissions: '0600'
+    content: |
+{{.EnvFile}}
+{{- end }}
+  - path: /etc/systemd/system/livekit-docker.service
+    content: |
+{{.SystemService}}
+{{- if .RedisConf }}
+  - path: {{.InstallPrefix}}/redis.conf
+    permissions: '0600'
+    content: |
+{{.RedisConf}}
+{{- end }}
+{{- if .EgressConf }}
+  - path: {{.InstallPrefix}}/egress.yaml
+    permissions: '0600'
+    content: |
+{{.EgressConf}}
+{{- end }}
+{{- if .IngressConf }}
+  - path: {{.InstallPrefix}}/ingress.yaml
+    permissions: '0600'
+    content: |
+{{.IngressConf}}
+{{- end }}
+{{- range .Certificates }}
+  - path: {{$.InstallPrefix}}/{{.Path}}
+    permissions: '0600'
+    content: |
+{{.Content}}
+{{- end }}
+{{- if .FirewallScript }}
+  - path: {{.InstallPrefix}}/firewall.sh
+    permissions: '0755'
+    content: |
+{{.FirewallScript}}
+{{- end }}
+
+runcmd:
+  - mkdir -p /usr/local/lib/docker/cli-plugins
+  - curl -fsSL "https://github.com/docker/compose/releases/download/v2.20.2/docker-compose-linux-$(uname -m)" -o /usr/local/lib/docker/cli-plugins/docker-compose
+  - chmod 755 /usr/local/lib/docker/cli-plugins/docker-compose
+  - systemctl enable --now docker
+  # open the ports when firewalld is running
+  - if systemctl is-active --quiet firewalld; then firewall-cmd --permanent{{range .FirewallPorts}} --add-port={{.}}{{end}} && firewall-cmd --reload; fi
+  # allow the containers to read the configs when SELinux is enforcing
+  - if [ "$(getenforce 2>/dev/null)" = "Enforcing" ]; then semanage fcontext -a -t container_file_t "{{.InstallPrefix}}(/.*)?" && restorecon -R {{.InstallPrefix}}; fi
+{{- if .FirewallScript }}
+  - {{.InstallPrefix}}/firewall.sh
+{{- end }}
+{{- if .UpdateIPScript }}
+  - chmod 755 {{.InstallPrefix}}/update_ip.sh
+  - {{.InstallPrefix}}/update_ip.sh
+{{- end }}
+  - systemctl enable livekit-docker
+  - systemctl start livekit-docker
+`
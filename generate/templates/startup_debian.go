@@ -0,0 +1,94 @@
+package templates
+
+// CloudInitDebianTemplate installs Docker and the compose plugin from Docker's repository, for Debian 12
+const CloudInitDebianTemplate = `#cloud-config
+# This file is used as a user-data script to start a VM
+# It'll upload configs to the right location and install LiveKit as a systemd service
+# LiveKit will be started automatically at machine startup
+package_update: true
+package_upgrade: all
+
+packages:
+  - ca-certificates
+  - curl
+  - gnupg
+
+bootcmd:
+  - mkdir -p {{.InstallPrefix}}/caddy_data
+  # the configs hold API secrets and passwords, only root can enter the install directory
+  - chmod 700 {{.InstallPrefix}}
+
+write_files:
+  - path: {{.InstallPrefix}}/livekit.yaml
+    permissions: '0600'
+    content: |
+{{.LiveKitConfig}}
+{{- if .CaddyConfig }}
+  - path: {{.InstallPrefix}}/caddy.yaml
+    permissions: '0600'
+    content: |
+{{.CaddyConfig}}
+  - path: {{.InstallPrefix}}/update_ip.sh
+    content: |
+{{.UpdateIPScript}}
+{{- end }}
+  - path: {{.InstallPrefix}}/docker-compose.yaml
+    content: |
+{{.DockerComposeConfig}}
+{{- if .EnvFile }}
+  - path: {{.InstallPrefix}}/.env
+    permissions: '0600'
+    content: |
+{{.EnvFile}}
+{{- end }}
+  - path: /etc/systemd/system/livekit-docker.service
+    content: |
+{{.SystemService}}
+{{- if .RedisConf }}
+  - path: {{.InstallPrefix}}/redis.conf
+    permissions: '0600'
+    content: |
+{{.RedisConf}}
+{{- end }}
+{{- if .EgressConf }}
+  - path: {{.InstallPrefix}}/egress.yaml
+    permissions: '0600'
+    content: |
+{{.EgressConf}}
+{{- end }}
+{{- if .IngressConf }}
+  - path: {{.InstallPrefix}}/ingress.yaml
+    permissions: '0600'
+    content: |
+{{.IngressConf}}
+{{- end }}
+{{- range .Certificates }}
+  - path: {{$.InstallPrefix}}/{{.Path}}
+    permissions: '0600'
+    content: |
+{{.Content}}
+{{- end }}
+{{- if .FirewallScript }}
+  - path: {{.InstallPrefix}}/firewall.sh
+    permissions: '0755'
+    content: |
+{{.FirewallScript}}
+{{- end }}
+
+runcmd:
+  - install -m 0755 -d /etc/apt/keyrings
+  - curl -fsSL https://download.docker.com/linux/debian/gpg -o /etc/apt/keyrings/docker.asc
+  - echo "deb [arch=$(dpkg --print-architecture) signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/debian $(. /etc/os-release && echo $VERSION_CODENAME) stable" > /etc/apt/sources.list.d/docker.list
+  - apt-get update
+  - apt-get install -y docker-ce docker-ce-cli containerd.io docker-compose-plugin
+  - systemctl enable --now docker
+{{- if .FirewallScript }}
+  - {{.InstallPrefix}}/firewall.sh
+{{- end }}
+{{- if .UpdateIPScript }}
+  - chmod 755 {{.InstallPrefix}}/update_ip.sh
+  - {{.InstallPrefix}}/update_ip.sh
+{{- end }}
+  - systemctl enable livekit-docker
+  - systemctl start livekit-docker
+`
@@ -7,6 +7,8 @@ const StartupScriptTemplate = `#!/bin/sh
 
 # create directories for LiveKit
 mkdir -p {{.InstallPrefix}}/caddy_data
+# the configs hold API secrets and passwords, only root can enter the install directory
+chmod 700 {{.InstallPrefix}}
 mkdir -p /usr/local/bin
 
 # Docker & Docker Compose will need to be installed on the machine
@@ -21,21 +23,33 @@ sudo systemctl enable docker
 cat << EOF > {{.InstallPrefix}}/livekit.yaml
 {{.LiveKitConfig}}
 EOF
+chmod 600 {{.InstallPrefix}}/livekit.yaml
+{{- if .CaddyConfig }}
 
 # caddy config
 cat << EOF > {{.InstallPrefix}}/caddy.yaml
 {{.CaddyConfig}}
 EOF
+chmod 600 {{.InstallPrefix}}/caddy.yaml
 
 # update ip script
 cat << "EOF" > {{.InstallPrefix}}/update_ip.sh
 {{.UpdateIPScript}}
 EOF
+{{- end }}
 
 # docker compose
 cat << EOF > {{.InstallPrefix}}/docker-compose.yaml
 {{.DockerComposeConfig}}
 EOF
+{{- if .EnvFile }}
+
+# API keys
+cat << EOF > {{.InstallPrefix}}/.env
+{{.EnvFile}}
+EOF
+chmod 600 {{.InstallPrefix}}/.env
+{{- end }}
 
 # systemd file
 cat << EOF > /etc/systemd/system/livekit-docker.service
@@ -47,6 +61,7 @@ EOF
 cat << EOF > {{.InstallPrefix}}/redis.conf
 {{.RedisConf}}
 EOF
+chmod 600 {{.InstallPrefix}}/redis.conf
 {{- end }}
 
 {{- if .EgressConf }}
@@ -54,6 +69,7 @@ EOF
 cat << EOF > {{.InstallPrefix}}/egress.yaml
 {{.EgressConf}}
 EOF
+chmod 600 {{.InstallPrefix}}/egress.yaml
 {{- end }}
 
 {{- if .IngressConf }}
@@ -61,10 +77,31 @@ EOF
 cat << EOF > {{.InstallPrefix}}/ingress.yaml
 {{.IngressConf}}
 EOF
+chmod 600 {{.InstallPrefix}}/ingress.yaml
 {{- end }}
 
+{{- range .Certificates }}
+# certificate
+mkdir -p {{$.InstallPrefix}}/certs
+cat << EOF > {{$.InstallPrefix}}/{{.Path}}
+{{.Content}}
+EOF
+chmod 600 {{$.InstallPrefix}}/{{.Path}}
+{{- end }}
+
+{{- if .FirewallScript }}
+# firewall
+cat << "EOF" > {{.InstallPrefix}}/firewall.sh
+{{.FirewallScript}}
+EOF
+chmod 755 {{.InstallPrefix}}/firewall.sh
+{{.InstallPrefix}}/firewall.sh
+{{- end }}
+{{- if .UpdateIPScript }}
+
 chmod 755 {{.InstallPrefix}}/update_ip.sh
 {{.InstallPrefix}}/update_ip.sh
+{{- end }}
 
 systemctl enable livekit-docker
 systemctl start livekit-docker
@@ -10,9 +10,373 @@ LimitNOFILE=500000
 Restart=always
 WorkingDirectory={{.InstallPrefix}}
 # Shutdown container (if running) when unit is started
-ExecStartPre=/usr/local/bin/docker-compose -f docker-compose.yaml down
-ExecStart=/usr/local/bin/docker-compose -f docker-compose.yaml up
-ExecStop=/usr/local/bin/docker-compose -f docker-compose.yaml down
+ExecStartPre={{.DockerCompose}} -f docker-compose.yaml down
+{{- if .ContainerConfigs }}
+# only the users of the containers can read their configs, which hold secrets. Their images are pulled to look
+# the users up, which can take longer than the default timeout
+TimeoutStartSec=infinity
+{{- range .ContainerConfigs }}
+ExecStartPre={{.ExecStartPre}}
+{{- end }}
+{{- end }}
+ExecStart={{.DockerCompose}} -f docker-compose.yaml up
+ExecStop={{.DockerCompose}} -f docker-compose.yaml down
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// units of the systemd target, running the services without Docker
+
+const SystemdLiveKitTemplate = `[Unit]
+Description=LiveKit Server
+After=network-online.target{{if .LocalRedis}} livekit-redis.service{{end}}
+Wants=network-online.target
+{{- if .LocalRedis }}
+Requires=livekit-redis.service
+{{- end }}
+
+[Service]
+User=livekit
+LimitNOFILE=500000
+Restart=always
+ExecStart=/usr/local/bin/livekit-server --config {{.InstallPrefix}}/livekit.yaml
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const SystemdCaddyTemplate = `[Unit]
+Description=Caddy for LiveKit
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+User=livekit
+# bind to 80 and 443 without running as root
+AmbientCapabilities=CAP_NET_BIND_SERVICE
+LimitNOFILE=500000
+Restart=always
+ExecStart=/usr/local/bin/caddy run --config {{.InstallPrefix}}/caddy.yaml --adapter yaml
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const SystemdRedisTemplate = `[Unit]
+Description=Redis for LiveKit
+After=network.target
+
+[Service]
+User=livekit
+Restart=always
+# Redis persists to its working directory
+WorkingDirectory={{.InstallPrefix}}/redis_data
+ExecStart=/usr/local/bin/redis-server {{.InstallPrefix}}/redis.conf
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// SystemdEgressTemplate runs Egress like its image does, with pulseaudio started for the recordings
+const SystemdEgressTemplate = `[Unit]
+Description=LiveKit Egress
+After=network-online.target livekit.service
+Wants=network-online.target
+
+[Service]
+User=livekit
+Restart=always
+# Chrome and pulseaudio keep their state in the home and runtime directories
+StateDirectory=livekit-egress
+RuntimeDirectory=livekit-egress
+Environment=HOME=/var/lib/livekit-egress
+Environment=XDG_RUNTIME_DIR=/run/livekit-egress
+Environment=EGRESS_CONFIG_FILE={{.InstallPrefix}}/egress.yaml
+ExecStartPre=/usr/bin/pulseaudio -D --exit-idle-time=-1 --disallow-exit
+ExecStart=/usr/local/bin/egress
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const SystemdIngressTemplate = `[Unit]
+Description=LiveKit Ingress
+After=network-online.target livekit.service
+Wants=network-online.target
+
+[Service]
+User=livekit
+Restart=always
+Environment=INGRESS_CONFIG_FILE={{.InstallPrefix}}/ingress.yaml
+ExecStart=/usr/local/bin/ingress
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const SystemdStartupScriptTemplate = `#!/bin/sh
+# This script will write all of your configurations to {{.InstallPrefix}}.
+# It installs LiveKit and Caddy from their release binaries, and runs them as systemd services without Docker.
+{{- if or .EgressConf .IngressConf }}
+# Egress and Ingress are only released as container images, they are built from source with GStreamer.
+{{- end }}
+set -e
+
+case $(uname -m) in
+  x86_64) ARCH=amd64 ;;
+  aarch64|arm64) ARCH=arm64 ;;
+  *) echo "unsupported architecture $(uname -m)"; exit 1 ;;
+esac
+
+# create user and directories for LiveKit
+id livekit >/dev/null 2>&1 || useradd --system --home-dir {{.InstallPrefix}} --shell /usr/sbin/nologin livekit
+mkdir -p {{.InstallPrefix}}/caddy_data {{.InstallPrefix}}/systemd
+mkdir -p /usr/local/bin
+
+# LiveKit server
+VERSION={{.ServerVersion}}
+if [ "$VERSION" = "latest" ]; then
+  VERSION=$(curl -fsSL -o /dev/null -w '%{url_effective}' https://github.com/livekit/livekit/releases/latest | sed 's|.*/tag/||')
+fi
+curl -fsSL "https://github.com/livekit/livekit/releases/download/${VERSION}/livekit_${VERSION#v}_linux_${ARCH}.tar.gz" | tar -xz -C /usr/local/bin livekit-server
+chmod 755 /usr/local/bin/livekit-server
+{{- if .RedisConf }}
+
+# Redis from the distribution's packages, run with the config below instead of the packaged service
+if command -v apt-get >/dev/null; then
+  apt-get update && apt-get install -y redis-server
+elif command -v dnf >/dev/null; then
+  dnf install -y redis || dnf install -y redis6
+else
+  yum install -y redis
+fi
+systemctl disable --now redis-server redis redis6 2>/dev/null || true
+ln -sf "$(command -v redis-server || command -v redis6-server)" /usr/local/bin/redis-server
+mkdir -p {{.InstallPrefix}}/redis_data
+{{- end }}
+{{- if or .EgressConf .IngressConf }}
+
+# GStreamer, for building and running Egress and Ingress
+if command -v apt-get >/dev/null; then
+  apt-get update && apt-get install -y git gcc pkg-config \
+    libgstreamer1.0-dev libgstreamer-plugins-base1.0-dev libgstreamer-plugins-bad1.0-dev \
+    gstreamer1.0-plugins-base gstreamer1.0-plugins-good gstreamer1.0-plugins-bad gstreamer1.0-plugins-ugly \
+    gstreamer1.0-libav gstreamer1.0-nice gstreamer1.0-pulseaudio gstreamer1.0-x
+elif command -v dnf >/dev/null; then
+  # part of the plugins and headers come from EPEL and CRB
+  dnf install -y epel-release || true
+  dnf config-manager --set-enabled crb || true
+  dnf install -y git gcc pkgconf-pkg-config \
+    gstreamer1-devel gstreamer1-plugins-base-devel gstreamer1-plugins-bad-free-devel \
+    gstreamer1-plugins-base gstreamer1-plugins-good gstreamer1-plugins-bad-free gstreamer1-plugins-ugly-free \
+    libnice-gstreamer1
+else
+  echo "building Egress and Ingress requires apt-get or dnf"
+  exit 1
+fi
+{{- end }}
+{{- if or .CaddyConfig .EgressConf .IngressConf }}
+
+# Go toolchain, verified with the checksum published with the release. The builds below verify their modules
+# with Go's checksum database
+GO_VERSION=$(curl -fsSL "https://go.dev/VERSION?m=text" | head -n 1)
+GO_ARCHIVE="${GO_VERSION}.linux-${ARCH}.tar.gz"
+BUILD_DIR=$(mktemp -d)
+curl -fsSL "https://dl.google.com/go/${GO_ARCHIVE}" -o "${BUILD_DIR}/${GO_ARCHIVE}"
+echo "$(curl -fsSL "https://dl.google.com/go/${GO_ARCHIVE}.sha256")  ${BUILD_DIR}/${GO_ARCHIVE}" | sha256sum -c -
+tar -xzf "${BUILD_DIR}/${GO_ARCHIVE}" -C "${BUILD_DIR}"
+export GOROOT="${BUILD_DIR}/go" GOPATH="${BUILD_DIR}/gopath" GOCACHE="${BUILD_DIR}/cache"
+export PATH="${GOROOT}/bin:${PATH}"
+{{- end }}
+{{- if .CaddyConfig }}
+
+# Caddy with the modules required by caddy.yaml, built with xcaddy
+CGO_ENABLED=0 go run github.com/caddyserver/xcaddy/cmd/xcaddy@latest build \
+  --output /usr/local/bin/caddy{{range .CaddyModules}} --with {{.}}{{end}}
+chmod 755 /usr/local/bin/caddy
+
+# the generator from the release that produced this script, its update-ip command points Caddy's TURN upstream to
+# the local IP
+GENERATOR_URL="https://github.com/livekit/deploy/releases/download/{{.GeneratorRelease}}/generate_linux_${ARCH}"
+curl -fsSL "${GENERATOR_URL}" -o "${BUILD_DIR}/generate"
+echo "$(curl -fsSL "${GENERATOR_URL}.sha256" | cut -d ' ' -f 1)  ${BUILD_DIR}/generate" | sha256sum -c -
+install -m 755 "${BUILD_DIR}/generate" /usr/local/bin/generate
+{{- end }}
+{{- if .EgressConf }}
+
+# Egress from its latest release, with Chrome for room composite and web recordings, and pulseaudio for their audio
+if command -v apt-get >/dev/null; then
+  apt-get install -y pulseaudio xvfb fonts-noto
+else
+  dnf install -y pulseaudio xorg-x11-server-Xvfb google-noto-sans-fonts
+fi
+if [ "$ARCH" = "amd64" ]; then
+  if command -v apt-get >/dev/null; then
+    curl -fsSL https://dl.google.com/linux/linux_signing_key.pub | gpg --dearmor --yes -o /usr/share/keyrings/google-chrome.gpg
+    echo "deb [arch=amd64 signed-by=/usr/share/keyrings/google-chrome.gpg] https://dl.google.com/linux/chrome/deb/ stable main" > /etc/apt/sources.list.d/google-chrome.list
+    apt-get update && apt-get install -y google-chrome-stable
+  else
+    cat << "EOF" > /etc/yum.repos.d/google-chrome.repo
+[google-chrome]
+name=google-chrome
+baseurl=https://dl.google.com/linux/chrome/rpm/stable/x86_64
+enabled=1
+gpgcheck=1
+gpgkey=https://dl.google.com/linux/linux_signing_key.pub
+EOF
+    dnf install -y google-chrome-stable
+  fi
+else
+  echo "Chrome is not released for $(uname -m), Egress can only record tracks and participants"
+fi
+EGRESS_VERSION=$(curl -fsSL -o /dev/null -w '%{url_effective}' https://github.com/livekit/egress/releases/latest | sed 's|.*/tag/||')
+git clone --depth 1 --branch "$EGRESS_VERSION" https://github.com/livekit/egress "${BUILD_DIR}/egress"
+(cd "${BUILD_DIR}/egress" && CGO_ENABLED=1 go build -o /usr/local/bin/egress ./cmd/server)
+chmod 755 /usr/local/bin/egress
+{{- end }}
+{{- if .IngressConf }}
+
+# Ingress from its latest release
+INGRESS_VERSION=$(curl -fsSL -o /dev/null -w '%{url_effective}' https://github.com/livekit/ingress/releases/latest | sed 's|.*/tag/||')
+git clone --depth 1 --branch "$INGRESS_VERSION" https://github.com/livekit/ingress "${BUILD_DIR}/ingress"
+(cd "${BUILD_DIR}/ingress" && CGO_ENABLED=1 go build -o /usr/local/bin/ingress ./cmd/server)
+chmod 755 /usr/local/bin/ingress
+{{- end }}
+{{- if or .CaddyConfig .EgressConf .IngressConf }}
+rm -rf "${BUILD_DIR}"
+{{- end }}
+
+# livekit config
+cat << EOF > {{.InstallPrefix}}/livekit.yaml
+{{.LiveKitConfig}}
+EOF
+{{- if .CaddyConfig }}
+
+# caddy config
+cat << EOF > {{.InstallPrefix}}/caddy.yaml
+{{.CaddyConfig}}
+EOF
+
+# update ip script
+cat << "EOF" > {{.InstallPrefix}}/update_ip.sh
+{{.UpdateIPScript}}
+EOF
+{{- end }}
+
+{{- if .RedisConf }}
+# redis config
+cat << EOF > {{.InstallPrefix}}/redis.conf
+{{.RedisConf}}
+EOF
+{{- end }}
+
+{{- if .EgressConf }}
+# egress config
+cat << EOF > {{.InstallPrefix}}/egress.yaml
+{{.EgressConf}}
+EOF
+{{- end }}
+
+{{- if .IngressConf }}
+# ingress config
+cat << EOF > {{.InstallPrefix}}/ingress.yaml
+{{.IngressConf}}
+EOF
+{{- end }}
+
+{{- range .Certificates }}
+# certificate
+mkdir -p {{$.InstallPrefix}}/certs
+cat << EOF > {{$.InstallPrefix}}/{{.Path}}
+{{.Content}}
+EOF
+chmod 600 {{$.InstallPrefix}}/{{.Path}}
+{{- end }}
+
+{{- if .FirewallScript }}
+# firewall
+cat << "EOF" > {{.InstallPrefix}}/firewall.sh
+{{.FirewallScript}}
+EOF
+chmod 755 {{.InstallPrefix}}/firewall.sh
+{{.InstallPrefix}}/firewall.sh
+{{- end }}
+
+{{- range .Units }}
+# systemd unit
+cat << EOF > {{$.InstallPrefix}}/systemd/{{.Path}}
+{{.Content}}
+EOF
+{{- end }}
+
+chown -R livekit:livekit {{.InstallPrefix}}
+# the configs hold API secrets and passwords, only LiveKit's user can read them
+chmod 700 {{.InstallPrefix}}
+chmod 600 {{.InstallPrefix}}/*.yaml{{if .RedisConf}} {{.InstallPrefix}}/redis.conf{{end}}
+{{- if .UpdateIPScript }}
+chmod 755 {{.InstallPrefix}}/update_ip.sh
+{{.InstallPrefix}}/update_ip.sh
+{{- end }}
+
+for unit in {{.InstallPrefix}}/systemd/*.service; do
+  systemctl enable --now "$unit"
+done
+`
+
+// IgnitionUpdateIPUnit runs the update IP script once at boot, before the services start
+const IgnitionUpdateIPUnit = `[Unit]
+Description=Update the TURN upstream of Caddy with the local IP
+# the generator runs from its image
+After=network-online.target docker.service
+Wants=network-online.target
+Before=livekit-docker.service livekit-caddy.service
+
+[Service]
+Type=oneshot
+ExecStart={{.InstallPrefix}}/update_ip.sh
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// IgnitionComposeScript installs the docker-compose release for the architecture of the host,
+// verified with the checksum published next to it
+const IgnitionComposeScript = `#!/bin/sh
+set -e
+
+URL="{{.ComposeURL}}-$(uname -m)"
+curl -fsSL -o /tmp/docker-compose "$URL"
+echo "$(curl -fsSL "$URL.sha256" | cut -d ' ' -f 1)  /tmp/docker-compose" | sha256sum -c -
+install -D -m 755 /tmp/docker-compose {{.ComposePath}}
+rm /tmp/docker-compose
+`
+
+// IgnitionComposeUnit runs the docker-compose install script at the first boot
+const IgnitionComposeUnit = `[Unit]
+Description=Install docker-compose
+After=network-online.target
+Wants=network-online.target
+Before=livekit-docker.service
+ConditionPathExists=!{{.ComposePath}}
+
+[Service]
+Type=oneshot
+ExecStart={{.InstallPrefix}}/install_compose.sh
+
+[Install]
+WantedBy=multi-user.target
+RequiredBy=livekit-docker.service
+`
+
+// IgnitionFirewallUnit applies the firewall script at boot, before the services start
+const IgnitionFirewallUnit = `[Unit]
+Description=Open the ports of LiveKit in the firewall
+Before=livekit-docker.service livekit-caddy.service
+
+[Service]
+Type=oneshot
+ExecStart={{.InstallPrefix}}/firewall.sh
 
 [Install]
 WantedBy=multi-user.target
@@ -0,0 +1,166 @@
+package templates
+
+const TerraformAWSTemplate = `# Launches the LiveKit deployment for {{.Domain}} on AWS EC2, with the startup script as user data
+# terraform init && terraform apply
+
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = ">= 5.0"
+    }
+  }
+}
+
+variable "region" {
+  description = "AWS region to launch the server in"
+  type        = string
+}
+
+variable "instance_type" {
+  description = "EC2 instance type"
+  type        = string
+  default     = "{{.InstanceType}}"
+}
+
+variable "key_name" {
+  description = "name of an EC2 key pair for SSH access, optional"
+  type        = string
+  default     = null
+}
+
+variable "ssh_cidr_blocks" {
+  description = "CIDR blocks allowed to connect with SSH"
+  type        = list(string)
+  default     = []
+}
+
+variable "route53_zone_id" {
+  description = "Route53 hosted zone to create the DNS records in, records are skipped when empty"
+  type        = string
+  default     = ""
+}
+
+provider "aws" {
+  region = var.region
+}
+
+data "aws_ami" "server" {
+  most_recent = true
+  owners      = ["{{.AMIOwner}}"]
+
+  filter {
+    name   = "name"
+    values = ["{{.AMIName}}"]
+  }
+
+  filter {
+    name   = "architecture"
+    values = ["x86_64"]
+  }
+}
+
+resource "aws_security_group" "livekit" {
+  name        = "{{.Name}}"
+  description = "LiveKit {{.Domain}}"
+{{- range .Ports }}
+
+  ingress {
+    description      = "{{.Description}}"
+    from_port        = {{.Port}}
+    to_port          = {{.LastPort}}
+    protocol         = "{{.Protocol}}"
+    cidr_blocks      = ["0.0.0.0/0"]
+    ipv6_cidr_blocks = ["::/0"]
+  }
+{{- end }}
+
+  dynamic "ingress" {
+    for_each = length(var.ssh_cidr_blocks) > 0 ? [1] : []
+    content {
+      description = "SSH"
+      from_port   = 22
+      to_port     = 22
+      protocol    = "tcp"
+      cidr_blocks = var.ssh_cidr_blocks
+    }
+  }
+
+  egress {
+    from_port        = 0
+    to_port          = 0
+    protocol         = "-1"
+    cidr_blocks      = ["0.0.0.0/0"]
+    ipv6_cidr_blocks = ["::/0"]
+  }
+}
+
+resource "aws_instance" "livekit" {
+  ami                    = data.aws_ami.server.id
+  instance_type          = var.instance_type
+  key_name               = var.key_name
+  vpc_security_group_ids = [aws_security_group.livekit.id]
+{{- if .DualStack }}
+  # requires a subnet with an IPv6 CIDR block, which the default VPC doesn't have
+  ipv6_address_count = 1
+{{- end }}
+
+  # the server is replaced when the startup script changes
+  user_data_replace_on_change = true
+{{- if .Gzip }}
+  # compressed to stay below the 16KB limit of user data, cloud-init decompresses it
+  user_data_base64 = base64gzip(file("${path.module}/../{{.UserData}}"))
+{{- else }}
+  user_data = file("${path.module}/../{{.UserData}}")
+{{- end }}
+
+  root_block_device {
+    volume_size = 20
+  }
+
+  tags = {
+    Name = "{{.Name}}"
+  }
+}
+
+resource "aws_eip" "livekit" {
+  instance = aws_instance.livekit.id
+  domain   = "vpc"
+
+  tags = {
+    Name = "{{.Name}}"
+  }
+}
+{{- range $i, $domain := .Domains }}
+
+resource "aws_route53_record" "domain_{{$i}}" {
+  count   = var.route53_zone_id != "" ? 1 : 0
+  zone_id = var.route53_zone_id
+  name    = "{{$domain}}"
+  type    = "A"
+  ttl     = 300
+  records = [aws_eip.livekit.public_ip]
+}
+{{- if $.DualStack }}
+
+resource "aws_route53_record" "domain_{{$i}}_ipv6" {
+  count   = var.route53_zone_id != "" ? 1 : 0
+  zone_id = var.route53_zone_id
+  name    = "{{$domain}}"
+  type    = "AAAA"
+  ttl     = 300
+  records = aws_instance.livekit.ipv6_addresses
+}
+{{- end }}
+{{- end }}
+
+output "public_ip" {
+  value = aws_eip.livekit.public_ip
+}
+{{- if .DualStack }}
+
+output "ipv6_addresses" {
+  value = aws_instance.livekit.ipv6_addresses
+}
+{{- end }}
+`
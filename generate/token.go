@@ -0,0 +1,438 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/manifoldco/promptui"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/exp/slices"
+
+	"github.com/livekit/protocol/auth"
+)
+
+const (
+	flagAPIKey            = "api-key"
+	flagAPISecret         = "api-secret"
+	flagIdentity          = "identity"
+	flagName              = "name"
+	flagRoom              = "room"
+	flagValidFor          = "valid-for"
+	flagMetadata          = "metadata"
+	flagAttribute         = "attribute"
+	flagJoin              = "join"
+	flagPublish           = "publish"
+	flagSubscribe         = "subscribe"
+	flagPublishData       = "publish-data"
+	flagPublishSources    = "publish-sources"
+	flagUpdateOwnMetadata = "update-own-metadata"
+	flagRoomAdmin         = "room-admin"
+	flagRoomCreate        = "room-create"
+	flagRoomList          = "room-list"
+	flagRoomRecord        = "room-record"
+	flagIngressAdmin      = "ingress-admin"
+	flagRecorder          = "recorder"
+	flagHidden            = "hidden"
+	flagDecode            = "decode"
+
+	flagTestRoom     = "test-room"
+	flagTestTokenTTL = "test-token-ttl"
+	flagTestURL      = "test-url"
+
+	// meetURL connects to a server with a token, it doesn't need an account
+	meetURL = "https://meet.livekit.io/custom"
+)
+
+// defaults of the test token printed after generating a deployment, it tends to be pasted into chats
+const (
+	defaultTestRoom     = "my-first-room"
+	defaultTestTokenTTL = time.Hour
+)
+
+// trackSources are the values of canPublishSources
+var trackSources = []string{"camera", "microphone", "screen_share", "screen_share_audio"}
+
+var tokenFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    flagAPIKey,
+		Usage:   "API key to sign the token with, selects one of the keys in livekit.yaml when it has several",
+		EnvVars: []string{"LIVEKIT_API_KEY"},
+	},
+	&cli.StringFlag{
+		Name:    flagAPISecret,
+		Usage:   "API secret to sign the token with, instead of reading it from livekit.yaml",
+		EnvVars: []string{"LIVEKIT_API_SECRET"},
+	},
+	&cli.StringFlag{
+		Name:  flagIdentity,
+		Usage: "identity of the participant",
+	},
+	&cli.StringFlag{
+		Name:  flagName,
+		Usage: "display name of the participant",
+	},
+	&cli.StringFlag{
+		Name:  flagRoom,
+		Usage: "room the token grants access to",
+	},
+	&cli.DurationFlag{
+		Name:  flagValidFor,
+		Usage: "how long the token is valid for",
+		Value: 6 * time.Hour,
+	},
+	&cli.StringFlag{
+		Name:  flagMetadata,
+		Usage: "metadata of the participant",
+	},
+	&cli.StringSliceFlag{
+		Name:  flagAttribute,
+		Usage: "attribute of the participant as key=value, can be repeated",
+	},
+	&cli.BoolFlag{
+		Name:  flagJoin,
+		Usage: "allow joining the room, --join=false for tokens only managing it",
+		Value: true,
+	},
+	&cli.BoolFlag{
+		Name:  flagPublish,
+		Usage: "allow publishing tracks, all permissions are granted when none of the publish and subscribe flags are set",
+	},
+	&cli.BoolFlag{
+		Name:  flagSubscribe,
+		Usage: "allow subscribing to tracks",
+	},
+	&cli.BoolFlag{
+		Name:  flagPublishData,
+		Usage: "allow publishing data messages",
+	},
+	&cli.StringSliceFlag{
+		Name:  flagPublishSources,
+		Usage: "sources the participant may publish, any of " + strings.Join(trackSources, ", "),
+	},
+	&cli.BoolFlag{
+		Name:  flagUpdateOwnMetadata,
+		Usage: "allow the participant to update its own name and metadata",
+	},
+	&cli.BoolFlag{
+		Name:  flagRoomAdmin,
+		Usage: "allow managing the room and its participants",
+	},
+	&cli.BoolFlag{
+		Name:  flagRoomCreate,
+		Usage: "allow creating and deleting rooms",
+	},
+	&cli.BoolFlag{
+		Name:  flagRoomList,
+		Usage: "allow listing rooms",
+	},
+	&cli.BoolFlag{
+		Name:  flagRoomRecord,
+		Usage: "allow using Egress",
+	},
+	&cli.BoolFlag{
+		Name:  flagIngressAdmin,
+		Usage: "allow managing Ingress",
+	},
+	&cli.BoolFlag{
+		Name:  flagRecorder,
+		Usage: "mark the participant as a recorder",
+	},
+	&cli.BoolFlag{
+		Name:  flagHidden,
+		Usage: "hide the participant from the others in the room",
+	},
+	&cli.BoolFlag{
+		Name:  flagDecode,
+		Usage: "print the header and claims of the token as well",
+	},
+}
+
+// testTokenFlags control the test token printed after generating a deployment
+var testTokenFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  flagTestRoom,
+		Usage: "room the test token joins (default: " + defaultTestRoom + ")",
+	},
+	&cli.DurationFlag{
+		Name:  flagTestTokenTTL,
+		Usage: "how long the test token is valid for (default: 1h)",
+	},
+	&cli.BoolFlag{
+		Name:  flagTestURL,
+		Usage: "print a meet.livekit.io URL joining the test room, instead of the test token",
+	},
+}
+
+// testTokenOptions are the room and lifetime of the test token
+type testTokenOptions struct {
+	Room     string
+	ValidFor time.Duration
+	URL      bool
+}
+
+// resolveTestTokenOptions applies the test token flags, and prompts for the room and lifetime when interactive
+func resolveTestTokenOptions(c *cli.Context, interactive bool) (*testTokenOptions, error) {
+	opts := &testTokenOptions{
+		Room:     defaultTestRoom,
+		ValidFor: defaultTestTokenTTL,
+		URL:      c.Bool(flagTestURL),
+	}
+	if c.IsSet(flagTestRoom) {
+		opts.Room = c.String(flagTestRoom)
+	} else if interactive {
+		if err := promptTestRoom(opts); err != nil {
+			return nil, err
+		}
+	}
+	if c.IsSet(flagTestTokenTTL) {
+		opts.ValidFor = c.Duration(flagTestTokenTTL)
+	} else if interactive {
+		if err := promptTestTokenTTL(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Room == "" {
+		return nil, fmt.Errorf("--%s cannot be empty", flagTestRoom)
+	}
+	if opts.ValidFor <= 0 {
+		return nil, fmt.Errorf("--%s must be positive", flagTestTokenTTL)
+	}
+	return opts, nil
+}
+
+func promptTestRoom(opts *testTokenOptions) error {
+	prompt := promptui.Prompt{
+		Label:   "Room of the test token",
+		Default: opts.Room,
+		Validate: func(s string) error {
+			if s == "" {
+				return errors.New("room cannot be empty")
+			}
+			return nil
+		},
+		Stdout: BellSkipper,
+	}
+	var err error
+	opts.Room, err = prompt.Run()
+	return err
+}
+
+func promptTestTokenTTL(opts *testTokenOptions) error {
+	prompt := promptui.Prompt{
+		Label:   "Test token valid for (i.e. 30m, 2h)",
+		Default: "1h",
+		Validate: func(s string) error {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			if d <= 0 {
+				return errors.New("must be positive")
+			}
+			return nil
+		},
+		Stdout: BellSkipper,
+	}
+	value, err := prompt.Run()
+	if err != nil {
+		return err
+	}
+	opts.ValidFor, err = time.ParseDuration(value)
+	return err
+}
+
+// testMeetURL opens the room of a token on meet.livekit.io
+func testMeetURL(serverURL, token string) string {
+	query := url.Values{}
+	query.Set("liveKitUrl", serverURL)
+	query.Set("token", token)
+	return meetURL + "?" + query.Encode()
+}
+
+// tokenClaims are the LiveKit claims of a token, ClaimGrants doesn't have attributes yet
+type tokenClaims struct {
+	auth.ClaimGrants
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// createToken prints an access token signed with the keys of a deployment
+func createToken(c *cli.Context) error {
+	apiKey, apiSecret, err := tokenKeys(c)
+	if err != nil {
+		return err
+	}
+
+	claims, err := tokenClaimsFromFlags(c)
+	if err != nil {
+		return err
+	}
+	token, err := signToken(apiKey, apiSecret, claims, c.Duration(flagValidFor))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(token)
+	if c.Bool(flagDecode) {
+		fmt.Println()
+		return printDecodedToken(token)
+	}
+	return nil
+}
+
+// tokenKeys reads the API key and secret from the flags, or from the livekit.yaml given as argument
+func tokenKeys(c *cli.Context) (string, string, error) {
+	apiKey := c.String(flagAPIKey)
+	apiSecret := c.String(flagAPISecret)
+	if apiSecret != "" {
+		if apiKey == "" {
+			return "", "", fmt.Errorf("--%s is required with --%s", flagAPIKey, flagAPISecret)
+		}
+		return apiKey, apiSecret, nil
+	}
+
+	if c.Args().Len() == 0 {
+		return "", "", fmt.Errorf("pass a livekit.yaml, or --%s and --%s", flagAPIKey, flagAPISecret)
+	}
+	conf, _, err := loadLiveKitConfig(c.Args().First())
+	if err != nil {
+		return "", "", err
+	}
+	if apiKey == "" && len(conf.Keys) > 1 {
+		return "", "", fmt.Errorf("%s has several api keys, select one with --%s: %s",
+			c.Args().First(), flagAPIKey, strings.Join(sortedKeys(conf.Keys), ", "))
+	}
+	apiKey, apiSecret, err = getAPIKeySecret(conf, apiKey)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", c.Args().First(), err)
+	}
+	return apiKey, apiSecret, nil
+}
+
+func tokenClaimsFromFlags(c *cli.Context) (*tokenClaims, error) {
+	claims := &tokenClaims{
+		ClaimGrants: auth.ClaimGrants{
+			Identity: c.String(flagIdentity),
+			Name:     c.String(flagName),
+			Metadata: c.String(flagMetadata),
+			Video: &auth.VideoGrant{
+				Room:         c.String(flagRoom),
+				RoomAdmin:    c.Bool(flagRoomAdmin),
+				RoomCreate:   c.Bool(flagRoomCreate),
+				RoomList:     c.Bool(flagRoomList),
+				RoomRecord:   c.Bool(flagRoomRecord),
+				IngressAdmin: c.Bool(flagIngressAdmin),
+				Recorder:     c.Bool(flagRecorder),
+				Hidden:       c.Bool(flagHidden),
+			},
+		},
+	}
+	grant := claims.Video
+
+	if c.Bool(flagJoin) {
+		if grant.Room == "" {
+			return nil, fmt.Errorf("--%s is required to join a room, or pass --%s=false", flagRoom, flagJoin)
+		}
+		if claims.Identity == "" {
+			return nil, fmt.Errorf("--%s is required to join a room", flagIdentity)
+		}
+		grant.RoomJoin = true
+	}
+	if grant.RoomAdmin && grant.Room == "" {
+		return nil, fmt.Errorf("--%s is required with --%s", flagRoom, flagRoomAdmin)
+	}
+
+	// permissions left unset are granted by the server
+	if c.IsSet(flagPublish) {
+		grant.SetCanPublish(c.Bool(flagPublish))
+	}
+	if c.IsSet(flagSubscribe) {
+		grant.SetCanSubscribe(c.Bool(flagSubscribe))
+	}
+	if c.IsSet(flagPublishData) {
+		grant.SetCanPublishData(c.Bool(flagPublishData))
+	}
+	if c.IsSet(flagUpdateOwnMetadata) {
+		grant.SetCanUpdateOwnMetadata(c.Bool(flagUpdateOwnMetadata))
+	}
+	for _, s := range c.StringSlice(flagPublishSources) {
+		for _, source := range strings.Split(s, ",") {
+			source = strings.TrimSpace(source)
+			if !slices.Contains(trackSources, source) {
+				return nil, fmt.Errorf("invalid source %q, expected one of %s", source, strings.Join(trackSources, ", "))
+			}
+			grant.CanPublishSources = append(grant.CanPublishSources, source)
+		}
+	}
+
+	for _, v := range c.StringSlice(flagAttribute) {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid attribute %q, expected key=value", v)
+		}
+		if claims.Attributes == nil {
+			claims.Attributes = make(map[string]string)
+		}
+		claims.Attributes[key] = value
+	}
+	return claims, nil
+}
+
+// signToken signs the claims like auth.AccessToken does
+func signToken(apiKey, apiSecret string, claims *tokenClaims, validFor time.Duration) (string, error) {
+	if apiKey == "" || apiSecret == "" {
+		return "", auth.ErrKeysMissing
+	}
+	if validFor <= 0 {
+		return "", errors.New("token validity must be positive")
+	}
+
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(apiSecret)},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	cl := jwt.Claims{
+		Issuer:    apiKey,
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(validFor)),
+		Subject:   claims.Identity,
+	}
+	return jwt.Signed(sig).Claims(cl).Claims(claims).CompactSerialize()
+}
+
+func printDecodedToken(token string) error {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return err
+	}
+	claims := make(map[string]interface{})
+	if err = parsed.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return err
+	}
+	for _, header := range parsed.Headers {
+		data, err := json.MarshalIndent(map[string]interface{}{"alg": header.Algorithm, "typ": header.ExtraHeaders["typ"]}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println("Header:")
+		fmt.Println(string(data))
+	}
+	data, err := json.MarshalIndent(claims, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println("Claims:")
+	fmt.Println(string(data))
+	if exp, ok := claims["exp"].(float64); ok {
+		fmt.Println("Expires:", time.Unix(int64(exp), 0).Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/livekit/protocol/auth"
+)
+
+func TestSignToken(t *testing.T) {
+	claims := &tokenClaims{
+		ClaimGrants: auth.ClaimGrants{
+			Identity: "alice",
+			Name:     "Alice",
+			Video:    &auth.VideoGrant{Room: "my-room", RoomJoin: true, Hidden: true},
+		},
+		Attributes: map[string]string{"team": "red"},
+	}
+	claims.Video.SetCanPublish(false)
+
+	token, err := signToken("APIkey", "secretsecretsecretsecretsecret", claims, time.Hour)
+	require.NoError(t, err)
+
+	verifier, err := auth.ParseAPIToken(token)
+	require.NoError(t, err)
+	require.Equal(t, "APIkey", verifier.APIKey())
+	grants, err := verifier.Verify("secretsecretsecretsecretsecret")
+	require.NoError(t, err)
+	require.Equal(t, "alice", grants.Identity)
+	require.Equal(t, "Alice", grants.Name)
+	require.Equal(t, "my-room", grants.Video.Room)
+	require.True(t, grants.Video.Hidden)
+	require.False(t, grants.Video.GetCanPublish())
+	require.True(t, grants.Video.GetCanSubscribe())
+
+	parsed, err := jwt.ParseSigned(token)
+	require.NoError(t, err)
+	decoded := &tokenClaims{}
+	require.NoError(t, parsed.UnsafeClaimsWithoutVerification(decoded))
+	require.Equal(t, claims.Attributes, decoded.Attributes)
+
+	_, err = signToken("APIkey", "", claims, time.Hour)
+	require.ErrorIs(t, err, auth.ErrKeysMissing)
+}
+
+// testFlagContext parses args as a command with the flags would
+func testFlagContext(t *testing.T, flags []cli.Flag, args ...string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range flags {
+		require.NoError(t, f.Apply(set))
+	}
+	require.NoError(t, set.Parse(args))
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func testTokenContext(t *testing.T, args ...string) *cli.Context {
+	return testFlagContext(t, testTokenFlags, args...)
+}
+
+func TestResolveTestTokenOptions(t *testing.T) {
+	opts, err := resolveTestTokenOptions(testTokenContext(t), false)
+	require.NoError(t, err)
+	require.Equal(t, &testTokenOptions{Room: defaultTestRoom, ValidFor: defaultTestTokenTTL}, opts)
+
+	opts, err = resolveTestTokenOptions(testTokenContext(t, "--test-room", "standup", "--test-token-ttl", "10m", "--test-url"), false)
+	require.NoError(t, err)
+	require.Equal(t, &testTokenOptions{Room: "standup", ValidFor: 10 * time.Minute, URL: true}, opts)
+
+	_, err = resolveTestTokenOptions(testTokenContext(t, "--test-room", ""), false)
+	require.Error(t, err)
+	_, err = resolveTestTokenOptions(testTokenContext(t, "--test-token-ttl", "-1h"), false)
+	require.Error(t, err)
+}
+
+func TestMeetURL(t *testing.T) {
+	link := testMeetURL("wss://livekit.myhost.com", "header.claims+/=.signature")
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	require.Equal(t, meetURL, parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	require.Equal(t, "wss://livekit.myhost.com", parsed.Query().Get("liveKitUrl"))
+	require.Equal(t, "header.claims+/=.signature", parsed.Query().Get("token"))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/mediatransportutil/pkg/rtcconfig"
+)
+
+const (
+	flagIP = "ip"
+	// turnTLSPort is where Caddy forwards TURN/TLS after terminating TLS
+	turnTLSPort = "5349"
+)
+
+var updateIPFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  flagIP,
+		Usage: "IP to forward TURN/TLS to, instead of the first local IP",
+	},
+}
+
+// virtualInterfaces are prefixes of bridges and virtual links created by container runtimes,
+// their addresses can't be reached from other hosts
+var virtualInterfaces = []string{"docker", "br-", "veth", "cni", "podman", "virbr", "flannel"}
+
+// updateIP points Caddy's TURN/TLS upstream to a local IP, TURN/TLS with Firefox fails through the loopback address
+func updateIP(c *cli.Context) error {
+	caddyFile := path.Join(installPrefix, "caddy.yaml")
+	if c.Args().Len() > 0 {
+		caddyFile = c.Args().First()
+	}
+
+	ip := c.String(flagIP)
+	if ip == "" {
+		var err error
+		if ip, err = localIP(); err != nil {
+			return err
+		}
+	} else if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+		// LiveKit's TURN server only listens on IPv4, Caddy accepts IPv6 clients and forwards them over IPv4
+		return fmt.Errorf("invalid IPv4 address %s", ip)
+	}
+
+	data, err := os.ReadFile(caddyFile)
+	if err != nil {
+		return err
+	}
+	updated, err := setTURNUpstream(data, ip)
+	if err != nil {
+		return fmt.Errorf("%s: %w", caddyFile, err)
+	}
+	if err = writeSecretFile(caddyFile, updated); err != nil {
+		return err
+	}
+	fmt.Printf("TURN/TLS upstream in %s set to %s:%s\n", caddyFile, ip, turnTLSPort)
+	return nil
+}
+
+// localIP is the first IP of a physical interface
+func localIP() (string, error) {
+	addresses, err := rtcconfig.GetLocalIPAddresses(false)
+	if err != nil {
+		return "", err
+	}
+
+	virtual := make(map[string]bool)
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		if !isVirtualInterface(iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				virtual[ipNet.IP.String()] = true
+			}
+		}
+	}
+
+	for _, address := range addresses {
+		if !virtual[address] && !net.ParseIP(address).IsLoopback() {
+			return address, nil
+		}
+	}
+	return "", errors.New("could not find the IP of a physical interface, set it with --ip")
+}
+
+func isVirtualInterface(name string) bool {
+	for _, prefix := range virtualInterfaces {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setTURNUpstream replaces the host of the layer4 upstreams dialing the TURN/TLS port
+func setTURNUpstream(data []byte, ip string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	servers := lookupNode(&doc, "apps", "layer4", "servers")
+	if servers == nil || servers.Kind != yaml.MappingNode {
+		return nil, errors.New("no layer4 servers found")
+	}
+	updated := 0
+	for i := 1; i < len(servers.Content); i += 2 {
+		routes := lookupNode(servers.Content[i], "routes")
+		if routes == nil {
+			continue
+		}
+		for _, route := range routes.Content {
+			handlers := lookupNode(route, "handle")
+			if handlers == nil {
+				continue
+			}
+			for _, handler := range handlers.Content {
+				upstreams := lookupNode(handler, "upstreams")
+				if upstreams == nil {
+					continue
+				}
+				for _, upstream := range upstreams.Content {
+					dial := lookupNode(upstream, "dial")
+					if dial == nil {
+						continue
+					}
+					for _, address := range dial.Content {
+						_, port, err := net.SplitHostPort(address.Value)
+						if err != nil || port != turnTLSPort {
+							continue
+						}
+						address.Value = net.JoinHostPort(ip, port)
+						updated++
+					}
+				}
+			}
+		}
+	}
+	if updated == 0 {
+		return nil, fmt.Errorf("no upstream dialing port %s found", turnTLSPort)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lookupNode follows keys through nested mappings, nil when one of them is missing
+func lookupNode(node *yaml.Node, keys ...string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/deploy/generate/templates"
+)
+
+func TestSetTURNUpstream(t *testing.T) {
+	opts := &ServerOptions{
+		Domain:     "livekit.myhost.com",
+		TURNDomain: "livekit-turn.myhost.com",
+		WHIPDomain: "livekit-whip.myhost.com",
+	}
+	tmpl, err := template.New("caddy").Parse(templates.CaddyConfigTemplate)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	require.NoError(t, tmpl.Execute(buf, opts))
+
+	for _, ip := range []string{"10.0.0.2", "192.168.1.2"} {
+		updated, err := setTURNUpstream(buf.Bytes(), ip)
+		require.NoError(t, err)
+
+		var caddy struct {
+			Apps struct {
+				Layer4 struct {
+					Servers map[string]struct {
+						Routes []struct {
+							Handle []struct {
+								Upstreams []struct {
+									Dial []string `yaml:"dial"`
+								} `yaml:"upstreams"`
+							} `yaml:"handle"`
+						} `yaml:"routes"`
+					} `yaml:"servers"`
+				} `yaml:"layer4"`
+			} `yaml:"apps"`
+		}
+		require.NoError(t, yaml.Unmarshal(updated, &caddy))
+		var dials []string
+		for _, route := range caddy.Apps.Layer4.Servers["main"].Routes {
+			for _, handler := range route.Handle {
+				for _, upstream := range handler.Upstreams {
+					dials = append(dials, upstream.Dial...)
+				}
+			}
+		}
+		// only the TURN upstream moves off localhost
+		require.Equal(t, []string{net.JoinHostPort(ip, "5349"), "localhost:7880", "localhost:8080"}, dials)
+	}
+
+	_, err = setTURNUpstream([]byte("apps:\n  http: {}\n"), "10.0.0.2")
+	require.Error(t, err)
+}